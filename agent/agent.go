@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
+)
+
+// SocketEnv overrides the path of the agent socket
+const SocketEnv = "MAROON_AGENT_SOCKET"
+
+// ErrNotRunning is returned by Client methods when no agent is listening on the socket
+var ErrNotRunning = errors.New("Maroon agent is not running")
+
+// ErrUnknownProfile is returned by Client.Credentials when the agent does not hold the profile
+var ErrUnknownProfile = errors.New("Maroon agent does not hold credentials for this profile")
+
+// Status describes a running agent
+type Status struct {
+	Pid      int             `json:"pid"`
+	Started  time.Time       `json:"started"`
+	Socket   string          `json:"socket"`
+	Profiles []ProfileStatus `json:"profiles"`
+}
+
+// ProfileStatus describes the credentials the agent holds for a profile
+type ProfileStatus struct {
+	Name       string     `json:"name"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	Refreshed  *time.Time `json:"refreshed,omitempty"`
+	NextRetry  *time.Time `json:"nextRetry,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// GetAgentDir returns the folder holding the agent socket and log. Only the current user can access it.
+func GetAgentDir() (string, error) {
+	configPath, err := config.GetMaroonConfigFile()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(configPath), "agent"), nil
+}
+
+// GetSocketPath returns the path of the agent socket, which can be overridden with MAROON_AGENT_SOCKET
+func GetSocketPath() (string, error) {
+	if socketPath := os.Getenv(SocketEnv); socketPath != "" {
+		return socketPath, nil
+	}
+
+	agentDir, err := GetAgentDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(agentDir, "agent.sock"), nil
+}
+
+// GetLogPath returns the file a detached agent writes its log to
+func GetLogPath() (string, error) {
+	agentDir, err := GetAgentDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(agentDir, "agent.log"), nil
+}
+
+// Listen creates the agent socket in a folder only the current user can access. A socket left behind by an agent
+// that did not shut down cleanly is replaced, a socket with a live agent behind it is an error.
+func Listen(socketPath string) (net.Listener, error) {
+	socketDir := filepath.Dir(socketPath)
+	if err := os.MkdirAll(socketDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "Cannot create agent folder")
+	}
+	if err := os.Chmod(socketDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "Cannot restrict access to agent folder")
+	}
+
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return nil, errors.New(fmt.Sprintf("A Maroon agent is already listening on '%s'", socketPath))
+		}
+		if err = os.Remove(socketPath); err != nil {
+			return nil, errors.Wrap(err, "Cannot remove stale agent socket")
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot listen on agent socket")
+	}
+
+	if err = os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, errors.Wrap(err, "Cannot restrict access to agent socket")
+	}
+
+	return listener, nil
+}
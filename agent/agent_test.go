@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent", "agent.sock")
+	listener, err := Listen(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Windows does not have Unix permission bits
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(filepath.Dir(socketPath)); err != nil {
+			t.Fatal(err)
+		} else if info.Mode().Perm() != 0700 {
+			t.Errorf("agent folder mode = %v, want 0700", info.Mode().Perm())
+		}
+		if info, err := os.Stat(socketPath); err != nil {
+			t.Fatal(err)
+		} else if info.Mode().Perm() != 0600 {
+			t.Errorf("agent socket mode = %v, want 0600", info.Mode().Perm())
+		}
+	}
+
+	if _, err = Listen(socketPath); err == nil {
+		t.Error("expected an error while another agent listens on the socket")
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	// A socket file without a listener, as left behind by an agent that was killed
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := Listen(socketPath)
+	if err != nil {
+		t.Fatalf("Listen() error = %v, want the stale socket replaced", err)
+	}
+	listener.Close()
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	v1 "github.com/hunoz/maroon-api/api/v1"
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout bounds requests to the agent, including a refresh the agent makes while answering
+const DefaultTimeout = 90 * time.Second
+
+// Client talks to a running agent over its socket
+type Client struct {
+	SocketPath string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the agent listening on socketPath
+func NewClient(socketPath string) *Client {
+	dialer := &net.Dialer{Timeout: 2 * time.Second}
+	return &Client{
+		SocketPath: socketPath,
+		HTTPClient: &http.Client{
+			Timeout: DefaultTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Credentials returns the credentials the agent holds for a profile
+func (c *Client) Credentials(profileName string) (types.Credentials, error) {
+	var output v1.JSONResponse[types.Credentials]
+	err := c.do(http.MethodGet, "credentials/"+url.PathEscape(profileName), &output)
+	return output.Data, err
+}
+
+// Status returns the state of the agent and the profiles it holds
+func (c *Client) Status() (*Status, error) {
+	var output v1.JSONResponse[Status]
+	if err := c.do(http.MethodGet, "status", &output); err != nil {
+		return nil, err
+	}
+	return &output.Data, nil
+}
+
+// Stop asks the agent to shut down
+func (c *Client) Stop() error {
+	return c.do(http.MethodPost, "stop", nil)
+}
+
+func (c *Client) do(method string, operation string, output interface{}) error {
+	request, err := http.NewRequest(method, "http://maroon-agent/v1/"+operation, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return ErrNotRunning
+		}
+		return errors.Wrap(err, "Error calling Maroon agent")
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrap(err, "Error reading Maroon agent response")
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrUnknownProfile
+	} else if response.StatusCode != http.StatusOK {
+		apiError := v1.JSONError{Error: &v1.Error{}}
+		message := strings.TrimSpace(string(body))
+		if err := json.Unmarshal(body, &apiError); err == nil {
+			if e, ok := apiError.Error.(*v1.Error); ok && e.Message != "" {
+				message = e.Message
+			}
+		}
+		return errors.New(fmt.Sprintf("Maroon agent returned %d: %s", response.StatusCode, message))
+	}
+
+	if output == nil {
+		return nil
+	}
+	if err = json.Unmarshal(body, output); err != nil {
+		return errors.Wrap(err, "Error decoding Maroon agent response")
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	v1 "github.com/hunoz/maroon-api/api/v1"
+	"github.com/pkg/errors"
+)
+
+// DefaultRetryDelay is how long the agent waits before retrying a failed refresh
+const DefaultRetryDelay = 30 * time.Second
+
+// Server holds credentials for a set of profiles in memory, refreshes them ahead of expiry and serves them over
+// the agent socket
+type Server struct {
+	Profiles []string
+	// Resolve returns credentials for a profile, refreshing them when they expire within refreshBefore
+	Resolve func(profileName string, refreshBefore time.Duration) (types.Credentials, error)
+	// RefreshBefore returns how long before expiry the credentials of a profile are refreshed
+	RefreshBefore func(profileName string) time.Duration
+	RetryDelay    time.Duration
+	Logf          func(format string, args ...interface{})
+
+	// resolveMu serializes calls to Resolve, which reads and writes shared config and cache files
+	resolveMu sync.Mutex
+	mu        sync.Mutex
+	entries   map[string]*entry
+	started   time.Time
+	socket    string
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+type entry struct {
+	credentials types.Credentials
+	refreshed   time.Time
+	nextRetry   time.Time
+	err         error
+}
+
+// Serve serves requests on listener until ctx is done or a stop request is received
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	s.entries = map[string]*entry{}
+	for _, profileName := range s.Profiles {
+		s.entries[profileName] = &entry{}
+	}
+	s.started = time.Now()
+	s.socket = listener.Addr().String()
+	s.stop = make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/credentials/", s.handleCredentials)
+	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/stop", s.handleStop)
+	httpServer := &http.Server{Handler: mux}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.stop:
+		}
+		cancel()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	go s.refreshLoop(ctx)
+
+	s.logf("Serving credentials for profiles %v on '%s'", s.Profiles, s.socket)
+	if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// refreshLoop refreshes every profile when its credentials are about to expire and sleeps until the next one is due
+func (s *Server) refreshLoop(ctx context.Context) {
+	for {
+		next := time.Hour
+		for _, profileName := range s.Profiles {
+			due := s.refreshDue(profileName)
+			if wait := time.Until(due); wait <= 0 {
+				s.refresh(profileName)
+				due = s.refreshDue(profileName)
+			}
+			if wait := time.Until(due); wait < next {
+				next = wait
+			}
+		}
+		// Credentials that are already due again right after a refresh must not be fetched in a tight loop
+		if next < s.RetryDelay {
+			next = s.RetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+	}
+}
+
+// refreshDue returns when the credentials of a profile should next be refreshed
+func (s *Server) refreshDue(profileName string) time.Time {
+	refreshBefore := s.RefreshBefore(profileName)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[profileName]
+	if e.err != nil {
+		return e.nextRetry
+	} else if e.credentials.Expiration == nil {
+		return time.Time{}
+	}
+	return e.credentials.Expiration.Add(-refreshBefore)
+}
+
+// refresh resolves the credentials of a profile and records the result
+func (s *Server) refresh(profileName string) {
+	s.resolveMu.Lock()
+	credentials, err := s.Resolve(profileName, s.RefreshBefore(profileName))
+	s.resolveMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[profileName]
+	if err != nil {
+		e.err = err
+		e.nextRetry = time.Now().Add(s.RetryDelay)
+		s.logf("Could not refresh credentials for profile '%s', retrying in %v: %v", profileName, s.RetryDelay, err.Error())
+		return
+	}
+
+	if e.credentials.Expiration == nil || !credentials.Expiration.Equal(*e.credentials.Expiration) {
+		e.refreshed = time.Now()
+		s.logf("Refreshed credentials for profile '%s', they expire at %s", profileName, credentials.Expiration.Local().Format(time.RFC1123))
+	}
+	e.credentials = credentials
+	e.err = nil
+	e.nextRetry = time.Time{}
+}
+
+// credentials returns the unexpired credentials held for a profile, refreshing them first when needed
+func (s *Server) credentials(profileName string) (types.Credentials, error) {
+	if due := s.refreshDue(profileName); time.Now().After(due) {
+		s.refresh(profileName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[profileName]
+	if e.credentials.Expiration == nil || !e.credentials.Expiration.After(time.Now()) {
+		if e.err != nil {
+			return types.Credentials{}, e.err
+		}
+		return types.Credentials{}, errors.New(fmt.Sprintf("No unexpired credentials for profile '%s'", profileName))
+	}
+
+	return e.credentials, nil
+}
+
+func (s *Server) handleCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	profileName := strings.TrimPrefix(r.URL.Path, "/v1/credentials/")
+	s.mu.Lock()
+	_, ok := s.entries[profileName]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Profile '%s' is not held by this agent", profileName))
+		return
+	}
+
+	credentials, err := s.credentials(profileName)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v1.JSONResponse[types.Credentials]{Data: credentials})
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	status := Status{
+		Pid:     os.Getpid(),
+		Started: s.started,
+		Socket:  s.socket,
+	}
+
+	s.mu.Lock()
+	for _, profileName := range s.Profiles {
+		e := s.entries[profileName]
+		profileStatus := ProfileStatus{Name: profileName, Expiration: e.credentials.Expiration}
+		if !e.refreshed.IsZero() {
+			refreshed := e.refreshed
+			profileStatus.Refreshed = &refreshed
+		}
+		if e.err != nil {
+			nextRetry := e.nextRetry
+			profileStatus.NextRetry = &nextRetry
+			profileStatus.Error = e.err.Error()
+		}
+		status.Profiles = append(status.Profiles, profileStatus)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, v1.JSONResponse[Status]{Data: status})
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	s.logf("Stopping on request")
+	writeJSON(w, http.StatusOK, v1.JSONResponse[struct{}]{})
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, v1.JSONError{Error: v1.Error{Message: message}})
+}
@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/pkg/errors"
+)
+
+// testResolver hands out credentials that expire after each of lifetimes in turn, then after an hour, and records
+// when it was called
+type testResolver struct {
+	mu        sync.Mutex
+	lifetimes []time.Duration
+	calls     []time.Time
+	err       error
+}
+
+func (r *testResolver) resolve(profileName string, refreshBefore time.Duration) (types.Credentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, time.Now())
+	if r.err != nil {
+		return types.Credentials{}, r.err
+	}
+
+	lifetime := time.Hour
+	if len(r.lifetimes) > 0 {
+		lifetime, r.lifetimes = r.lifetimes[0], r.lifetimes[1:]
+	}
+	return types.Credentials{
+		AccessKeyId:     aws.String("ASIA" + profileName),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+		Expiration:      aws.Time(time.Now().Add(lifetime)),
+	}, nil
+}
+
+func (r *testResolver) callTimes() []time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]time.Time(nil), r.calls...)
+}
+
+// startTestServer serves credentials for profiles on a temporary socket until the test ends
+func startTestServer(t *testing.T, resolver *testResolver, refreshBefore time.Duration, profiles ...string) (*Client, chan error) {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := Listen(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{
+		Profiles:      profiles,
+		Resolve:       resolver.resolve,
+		RefreshBefore: func(string) time.Duration { return refreshBefore },
+		RetryDelay:    10 * time.Millisecond,
+		Logf:          t.Logf,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, listener) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	client := NewClient(socketPath)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := client.Status(); err != nil; _, err = client.Status() {
+		if time.Now().After(deadline) {
+			t.Fatalf("agent did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return client, done
+}
+
+func TestServer(t *testing.T) {
+	resolver := &testResolver{}
+	client, done := startTestServer(t, resolver, 15*time.Minute, "dev")
+
+	credentials, err := client.Credentials("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(credentials.AccessKeyId) != "ASIAdev" {
+		t.Errorf("AccessKeyId = %q, want %q", aws.ToString(credentials.AccessKeyId), "ASIAdev")
+	}
+
+	if _, err = client.Credentials("prod"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Credentials() of a profile the agent does not hold = %v, want %v", err, ErrUnknownProfile)
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Profiles) != 1 || status.Profiles[0].Name != "dev" || status.Profiles[0].Expiration == nil {
+		t.Errorf("Status().Profiles = %+v, want the credentials held for 'dev'", status.Profiles)
+	}
+
+	if err = client.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-done:
+		done <- err
+		if err != nil {
+			t.Errorf("Serve() = %v after a stop request, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not stop")
+	}
+	if _, err = client.Status(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Status() after stop = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestServerRefreshesOnRequest(t *testing.T) {
+	// The first credentials are already within the refresh window when they are handed out
+	resolver := &testResolver{lifetimes: []time.Duration{10 * time.Minute}}
+	client, _ := startTestServer(t, resolver, 15*time.Minute, "dev")
+
+	credentials, err := client.Credentials("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(aws.ToTime(credentials.Expiration)) <= 15*time.Minute {
+		t.Errorf("credentials expire at %v, want them refreshed before being served", credentials.Expiration)
+	}
+}
+
+func TestServerResolveError(t *testing.T) {
+	resolver := &testResolver{err: errors.New("Maroon API is unavailable")}
+	client, _ := startTestServer(t, resolver, 15*time.Minute, "dev")
+
+	if _, err := client.Credentials("dev"); err == nil || errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Credentials() = %v, want the refresh error", err)
+	}
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Profiles) != 1 || status.Profiles[0].Error == "" || status.Profiles[0].NextRetry == nil {
+		t.Errorf("Status().Profiles = %+v, want the error and next retry of 'dev'", status.Profiles)
+	}
+}
+
+func TestRefreshLoop(t *testing.T) {
+	const refreshBefore = time.Minute
+
+	// The first credentials are due for a refresh 200ms after they are fetched
+	resolver := &testResolver{lifetimes: []time.Duration{refreshBefore + 200*time.Millisecond}}
+	server := &Server{
+		Profiles:      []string{"dev"},
+		Resolve:       resolver.resolve,
+		RefreshBefore: func(string) time.Duration { return refreshBefore },
+		RetryDelay:    10 * time.Millisecond,
+		entries:       map[string]*entry{"dev": {}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.refreshLoop(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	calls := resolver.callTimes()
+	for ; len(calls) < 2; calls = resolver.callTimes() {
+		if time.Now().After(deadline) {
+			t.Fatalf("refreshLoop resolved credentials %d times, want them renewed once they are within RefreshBefore", len(calls))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if wait := calls[1].Sub(calls[0]); wait < 150*time.Millisecond {
+		t.Errorf("credentials were renewed %v after they were fetched, before they were within RefreshBefore", wait)
+	}
+
+	// Renewed credentials are not due again for almost an hour
+	time.Sleep(100 * time.Millisecond)
+	if calls = resolver.callTimes(); len(calls) != 2 {
+		t.Errorf("refreshLoop resolved credentials %d times, want 2", len(calls))
+	}
+
+	server.mu.Lock()
+	expiration := server.entries["dev"].credentials.Expiration
+	server.mu.Unlock()
+	if expiration == nil || time.Until(*expiration) <= refreshBefore {
+		t.Errorf("held credentials expire at %v, want the renewed credentials", expiration)
+	}
+}
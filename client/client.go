@@ -0,0 +1,249 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/hunoz/maroon-api/api/v1"
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is used for Maroon API requests when no timeout is configured
+const DefaultTimeout = 30 * time.Second
+
+// Client calls the Maroon API on behalf of a user identified by Token
+type Client struct {
+	ApiUrl     string
+	Token      string
+	HTTPClient *http.Client
+	Retry      RetryPolicy
+	// Logf receives diagnostic messages such as retry summaries. Nothing is logged when it is nil.
+	Logf func(format string, args ...interface{})
+	// RefreshToken, when set, is called once after the Maroon API rejects Token as unauthorized. The request is
+	// retried with the token it returns.
+	RefreshToken func(ctx context.Context) (string, error)
+}
+
+// New creates a Maroon API client. apiUrl must include the API path prefix (i.e. https://api.example.com/api/v1).
+func New(apiUrl string, token string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Client{
+		ApiUrl: strings.TrimRight(apiUrl, "/"),
+		Token:  token,
+		HTTPClient: &http.Client{
+			Timeout: timeout,
+		},
+		Retry: DefaultRetryPolicy,
+	}
+}
+
+// AssumeRole fetches credentials for the role in input.RoleArn
+func (c *Client) AssumeRole(ctx context.Context, input v1.AssumeRoleInput) (*v1.AssumeRoleOutput, error) {
+	var output v1.JSONResponse[v1.AssumeRoleOutput]
+
+	query := url.Values{}
+	query.Set("roleArn", input.RoleArn)
+	query.Set("sessionDuration", strconv.FormatInt(int64(input.SessionDuration), 10))
+
+	if err := c.get(ctx, "assume-role", query, &output); err != nil {
+		return nil, errors.Wrap(err, "Error assuming role")
+	}
+
+	return &output.Data, nil
+}
+
+// GetConsoleUrl fetches a federated console sign in URL for an account
+func (c *Client) GetConsoleUrl(ctx context.Context, input v1.GetConsoleUrlInput) (*v1.GetConsoleUrlOutput, error) {
+	var output v1.JSONResponse[v1.GetConsoleUrlOutput]
+
+	query := url.Values{}
+	query.Set("accessType", string(input.AccessType))
+	query.Set("accountId", input.AccountId)
+	query.Set("duration", strconv.Itoa(input.Duration))
+
+	if err := c.get(ctx, "console-url", query, &output); err != nil {
+		return nil, errors.Wrap(err, "Error getting console url")
+	}
+
+	return &output.Data, nil
+}
+
+// RevokeSessionsInput selects the sessions of a role to revoke
+type RevokeSessionsInput struct {
+	RoleArn string
+	// AccessKeyId revokes the single session it belongs to. When empty, every session of the role issued before
+	// IssuedBefore is denied.
+	AccessKeyId  string
+	IssuedBefore time.Time
+}
+
+// RevokeSessionsOutput describes the sessions the Maroon API revoked
+type RevokeSessionsOutput struct {
+	RoleArn      string    `json:"roleArn"`
+	AccessKeyId  string    `json:"accessKeyId,omitempty"`
+	IssuedBefore time.Time `json:"issuedBefore"`
+}
+
+// RevokeSessions revokes sessions issued for a role, either a single access key or every session issued before a
+// point in time. It is not retried after server errors, since the revocation may already have been applied.
+func (c *Client) RevokeSessions(ctx context.Context, input RevokeSessionsInput) (*RevokeSessionsOutput, error) {
+	var output v1.JSONResponse[RevokeSessionsOutput]
+
+	query := url.Values{}
+	query.Set("roleArn", input.RoleArn)
+	if input.AccessKeyId != "" {
+		query.Set("accessKeyId", input.AccessKeyId)
+	} else {
+		query.Set("issuedBefore", input.IssuedBefore.UTC().Format(time.RFC3339))
+	}
+
+	if err := c.post(ctx, "revoke-sessions", query, &output); err != nil {
+		return nil, errors.Wrap(err, "Error revoking sessions")
+	}
+
+	return &output.Data, nil
+}
+
+// get performs a GET request against operation and unmarshals a successful response into output
+func (c *Client) get(ctx context.Context, operation string, query url.Values, output interface{}) error {
+	return c.call(ctx, http.MethodGet, operation, query, true, output)
+}
+
+// post performs a non-idempotent POST request against operation and unmarshals a successful response into output
+func (c *Client) post(ctx context.Context, operation string, query url.Values, output interface{}) error {
+	return c.call(ctx, http.MethodPost, operation, query, false, output)
+}
+
+// call performs a request against operation, retrying throttled and failed attempts according to c.Retry.
+// Failures other than throttling are only retried when the request is idempotent.
+func (c *Client) call(ctx context.Context, method string, operation string, query url.Values, idempotent bool, output interface{}) error {
+	start := time.Now()
+	if c.Retry.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Retry.Deadline)
+		defer cancel()
+	}
+
+	maxAttempts := c.Retry.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		err := c.do(ctx, method, operation, query, output)
+		if errors.Is(err, ErrUnauthorized) && c.RefreshToken != nil && !refreshed {
+			refreshed = true
+			token, refreshErr := c.RefreshToken(ctx)
+			if refreshErr != nil {
+				c.logf("Could not refresh token after Maroon API %s was unauthorized: %v", operation, refreshErr)
+				return err
+			}
+
+			c.logf("Refreshed token after Maroon API %s was unauthorized, retrying", operation)
+			c.Token = token
+			attempt--
+			continue
+		}
+
+		if err == nil {
+			if attempt > 1 {
+				c.logf("Maroon API %s succeeded after %d attempts in %v", operation, attempt, time.Since(start).Round(time.Millisecond))
+			}
+			return nil
+		}
+
+		if attempt >= maxAttempts || !isRetryable(ctx, err, idempotent) {
+			if attempt > 1 {
+				c.logf("Maroon API %s failed after %d attempts in %v", operation, attempt, time.Since(start).Round(time.Millisecond))
+			}
+			return err
+		}
+
+		wait := c.Retry.backoff(attempt)
+		var apiError *ApiError
+		if errors.As(err, &apiError) && apiError.RetryAfter > 0 {
+			wait = apiError.RetryAfter
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			c.logf("Maroon API %s failed after %d attempts in %v, not retrying past the deadline", operation, attempt, time.Since(start).Round(time.Millisecond))
+			return err
+		}
+
+		c.logf("Maroon API %s attempt %d/%d failed: %v. Retrying in %v", operation, attempt, maxAttempts, err, wait.Round(time.Millisecond))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// do performs a single request against operation and unmarshals a successful response into output
+func (c *Client) do(ctx context.Context, method string, operation string, query url.Values, output interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/%s?%s", c.ApiUrl, operation, query.Encode()), nil)
+	if err != nil {
+		return errors.Wrap(err, "Error creating Maroon API request")
+	}
+	req.Header.Set("Authorization", c.Token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "Error calling Maroon API")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "Error reading response from Maroon API")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiError := newApiError(operation, resp.StatusCode, decodeErrorMessage(body))
+		apiError.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return apiError
+	}
+
+	if err = json.Unmarshal(body, output); err != nil {
+		return errors.Wrap(err, "Error unmarshalling Maroon API response")
+	}
+
+	return nil
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+// decodeErrorMessage extracts the message from an error response body, falling back to the raw body
+func decodeErrorMessage(body []byte) string {
+	apiError := v1.JSONError{Error: &v1.Error{}}
+	if err := json.Unmarshal(body, &apiError); err == nil {
+		if e, ok := apiError.Error.(*v1.Error); ok && e.Message != "" {
+			return e.Message
+		}
+	}
+
+	message := strings.TrimSpace(string(body))
+	if len(message) > 200 {
+		message = message[:200]
+	}
+	return message
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrBadRequest   = errors.New("Invalid request")
+	ErrUnauthorized = errors.New("Invalid/expired token")
+	ErrForbidden    = errors.New("Not authorized to perform this operation")
+	ErrNotFound     = errors.New("Role or operation not found")
+	ErrThrottled    = errors.New("Request was throttled by the Maroon API")
+	ErrServer       = errors.New("Maroon API failed to process the request")
+	ErrUnexpected   = errors.New("Unexpected response from the Maroon API")
+)
+
+// ApiError is returned for any non-200 response from the Maroon API. It unwraps to one of the Err* values
+// above so callers can use errors.Is to react to a specific class of failure.
+type ApiError struct {
+	Operation  string
+	StatusCode int
+	Message    string
+	// RetryAfter is the wait requested by the server through the Retry-After header, if any
+	RetryAfter time.Duration
+	kind       error
+}
+
+func (e *ApiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %s (%d)", e.Operation, e.kind.Error(), e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %s (%d): %s", e.Operation, e.kind.Error(), e.StatusCode, e.Message)
+}
+
+func (e *ApiError) Unwrap() error {
+	return e.kind
+}
+
+func newApiError(operation string, statusCode int, message string) *ApiError {
+	var kind error
+	switch {
+	case statusCode == http.StatusBadRequest:
+		kind = ErrBadRequest
+	case statusCode == http.StatusUnauthorized:
+		kind = ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		kind = ErrForbidden
+	case statusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		kind = ErrThrottled
+	case statusCode >= 500:
+		kind = ErrServer
+	default:
+		kind = ErrUnexpected
+	}
+
+	return &ApiError{
+		Operation:  operation,
+		StatusCode: statusCode,
+		Message:    message,
+		kind:       kind,
+	}
+}
+
+// IsUnavailable reports whether err means the Maroon API could not be reached or could not serve the request, as
+// opposed to the API rejecting the request
+func IsUnavailable(err error) bool {
+	var apiError *ApiError
+	if errors.As(err, &apiError) {
+		return errors.Is(apiError, ErrThrottled) || errors.Is(apiError, ErrServer)
+	}
+
+	var netError net.Error
+	return errors.As(err, &netError) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// maxSessionDurationPattern finds the maximum session duration in seconds when the Maroon API reports it, i.e.
+// "MaxSessionDuration: 7200"
+var maxSessionDurationPattern = regexp.MustCompile(`(?i)max(imum)?\s*session\s*duration\D{0,20}?(\d+)`)
+
+// IsSessionDurationExceeded reports whether err means a role could not be assumed because the requested session
+// duration is longer than the role's MaxSessionDuration. Only messages naming DurationSeconds or MaxSessionDuration,
+// as STS does, are recognized, so other failures to assume a role are not retried with shorter sessions. The maximum
+// is returned when the Maroon API reported it.
+func IsSessionDurationExceeded(err error) (time.Duration, bool) {
+	var apiError *ApiError
+	if !errors.As(err, &apiError) || !errors.Is(apiError, ErrBadRequest) {
+		return 0, false
+	}
+
+	message := strings.ToLower(apiError.Message)
+	if !strings.Contains(message, "maxsessionduration") && !strings.Contains(message, "durationseconds") {
+		return 0, false
+	}
+
+	if match := maxSessionDurationPattern.FindStringSubmatch(apiError.Message); match != nil {
+		if seconds, err := strconv.Atoi(match[2]); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	return 0, true
+}
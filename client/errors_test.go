@@ -0,0 +1,49 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/hunoz/maroon-api/api/v1"
+)
+
+func TestIsSessionDurationExceeded(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		message     string
+		wantMatch   bool
+		wantMaximum time.Duration
+	}{
+		{"sts message", http.StatusBadRequest, "Unable to assume role: The requested DurationSeconds exceeds the MaxSessionDuration set for this role.", true, 0},
+		{"reported maximum", http.StatusBadRequest, "Unable to assume role, MaxSessionDuration: 7200", true, 2 * time.Hour},
+		{"generic unable to assume role", http.StatusBadRequest, "Unable to assume role", false, 0},
+		{"generic bad request", http.StatusBadRequest, "Bad Request", false, 0},
+		{"forbidden", http.StatusForbidden, "The requested DurationSeconds exceeds the MaxSessionDuration set for this role.", false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statusCode)
+				json.NewEncoder(w).Encode(v1.JSONError{Error: v1.Error{Message: test.message}})
+			}))
+			defer server.Close()
+
+			client := New(server.URL, "token", time.Second)
+			_, err := client.AssumeRole(context.Background(), v1.AssumeRoleInput{RoleArn: "arn:aws:iam::123456789012:role/r", SessionDuration: 43200})
+			if err == nil {
+				t.Fatal("AssumeRole succeeded")
+			}
+
+			maximum, ok := IsSessionDurationExceeded(err)
+			if ok != test.wantMatch || maximum != test.wantMaximum {
+				t.Fatalf("got %v, %v, want %v, %v", maximum, ok, test.wantMaximum, test.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy controls how failed Maroon API requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// Deadline bounds the total time spent on a request, including all retries and waits
+	Deadline  time.Duration
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created with New
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Deadline:    time.Minute,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// backoff returns the wait before the given retry (starting at 1) using exponential backoff with full jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.MaxDelay
+	if shift := retry - 1; shift < 32 {
+		if d := p.BaseDelay << uint(shift); d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// isRetryable reports whether err is worth retrying. Throttled requests were never processed so they are always
+// retried, while server and network failures are only retried for idempotent requests.
+func isRetryable(ctx context.Context, err error, idempotent bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiError *ApiError
+	if errors.As(err, &apiError) {
+		switch {
+		case errors.Is(apiError, ErrThrottled):
+			return true
+		case apiError.StatusCode == http.StatusNotImplemented:
+			return false
+		case errors.Is(apiError, ErrServer):
+			return idempotent
+		default:
+			return false
+		}
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return idempotent
+	}
+
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
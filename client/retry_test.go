@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/hunoz/maroon-api/api/v1"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for retry := 1; retry <= 64; retry++ {
+		ceiling := policy.MaxDelay
+		if retry <= 4 {
+			ceiling = policy.BaseDelay << uint(retry-1)
+		}
+
+		for i := 0; i < 100; i++ {
+			if wait := policy.backoff(retry); wait < 0 || wait > ceiling {
+				t.Fatalf("backoff(%d) = %v, want between 0 and %v", retry, wait, ceiling)
+			}
+		}
+	}
+
+	if wait := (RetryPolicy{}).backoff(1); wait != 0 {
+		t.Errorf("backoff without delays = %v, want 0", wait)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "3", 3 * time.Second},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-1", 0},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"http date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"invalid", "soon", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseRetryAfter(test.value, now); got != test.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+// testRetryPolicy retries quickly so tests do not wait on backoff
+var testRetryPolicy = RetryPolicy{MaxAttempts: 4, Deadline: 5 * time.Second, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+var testConsoleUrlInput = v1.GetConsoleUrlInput{AccountId: "123456789012", AccessType: "Administrator", Duration: 900}
+
+// newRetryTestServer responds with each of statuses in turn and then with success, counting the requests it receives
+func newRetryTestServer(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt := int(atomic.AddInt32(&requests, 1))
+		if attempt <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[attempt-1])
+			json.NewEncoder(w).Encode(v1.JSONError{Error: v1.Error{Message: "failed"}})
+			return
+		}
+
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestCallRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		idempotent   bool
+		statuses     []int
+		wantRequests int32
+		wantError    bool
+	}{
+		{"server errors are retried for idempotent calls", true, []int{500, 503}, 3, false},
+		{"gives up after max attempts", true, []int{502, 502, 502, 502}, 4, true},
+		{"server errors are not retried for non-idempotent calls", false, []int{503}, 1, true},
+		{"throttling is retried for non-idempotent calls", false, []int{429}, 2, false},
+		{"not implemented is not retried", true, []int{501}, 1, true},
+		{"bad requests are not retried", true, []int{400}, 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := newRetryTestServer(t, nil, test.statuses...)
+			client := New(server.URL, "token", time.Second)
+			client.Retry = testRetryPolicy
+
+			var err error
+			if test.idempotent {
+				_, err = client.GetConsoleUrl(context.Background(), testConsoleUrlInput)
+			} else {
+				_, err = client.RevokeSessions(context.Background(), RevokeSessionsInput{RoleArn: "arn:aws:iam::123456789012:role/r", IssuedBefore: time.Now()})
+			}
+
+			if (err != nil) != test.wantError {
+				t.Errorf("error = %v, want error %v", err, test.wantError)
+			}
+			if got := atomic.LoadInt32(requests); got != test.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, test.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCallWaitsForRetryAfter(t *testing.T) {
+	server, requests := newRetryTestServer(t, http.Header{"Retry-After": {"1"}}, http.StatusTooManyRequests)
+	client := New(server.URL, "token", time.Second)
+	client.Retry = testRetryPolicy
+
+	start := time.Now()
+	if _, err := client.GetConsoleUrl(context.Background(), testConsoleUrlInput); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, before the requested Retry-After of 1s", elapsed)
+	}
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("server received %d requests, want 2", got)
+	}
+}
+
+func TestCallStopsAtDeadline(t *testing.T) {
+	server, requests := newRetryTestServer(t, http.Header{"Retry-After": {"10"}}, http.StatusTooManyRequests)
+	client := New(server.URL, "token", time.Second)
+	client.Retry = testRetryPolicy
+	client.Retry.Deadline = 500 * time.Millisecond
+
+	start := time.Now()
+	_, err := client.GetConsoleUrl(context.Background(), testConsoleUrlInput)
+	if err == nil {
+		t.Fatal("expected the call to fail once the wait passes the deadline")
+	}
+	if !IsUnavailable(err) {
+		t.Errorf("error = %v, want the throttling error", err)
+	}
+	if elapsed := time.Since(start); elapsed >= client.Retry.Deadline {
+		t.Errorf("gave up after %v, want before the %v deadline", elapsed, client.Retry.Deadline)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
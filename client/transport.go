@@ -0,0 +1,89 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// TransportOptions configures how HTTP requests leave the machine. Empty fields keep Go's defaults, which
+// includes honoring the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type TransportOptions struct {
+	// Proxy is the URL of the proxy used for both http and https requests
+	Proxy string
+	// NoProxy is a comma separated list of hosts, domains and CIDRs that bypass the proxy
+	NoProxy string
+	// CABundle is the path to a PEM file of certificate authorities trusted in addition to the system ones
+	CABundle string
+	// ClientCert and ClientKey are the paths to a PEM certificate and key presented for mutual TLS
+	ClientCert string
+	ClientKey  string
+}
+
+// NewTransport creates an HTTP transport configured with the given options
+func NewTransport(options TransportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if options.Proxy != "" || options.NoProxy != "" {
+		proxyConfig := httpproxy.FromEnvironment()
+		if options.Proxy != "" {
+			if _, err := url.Parse(options.Proxy); err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("Invalid proxy URL '%s'", options.Proxy))
+			}
+			proxyConfig.HTTPProxy = options.Proxy
+			proxyConfig.HTTPSProxy = options.Proxy
+		}
+		if options.NoProxy != "" {
+			proxyConfig.NoProxy = options.NoProxy
+		}
+
+		proxyFunc := proxyConfig.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	if options.CABundle == "" && options.ClientCert == "" && options.ClientKey == "" {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if options.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := os.ReadFile(options.CABundle)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not read CA bundle")
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New(fmt.Sprintf("CA bundle '%s' does not contain any PEM certificates", options.CABundle))
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.ClientCert != "" || options.ClientKey != "" {
+		if options.ClientCert == "" || options.ClientKey == "" {
+			return nil, errors.New("Both a client certificate and a client key are required for mutual TLS")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(options.ClientCert, options.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
@@ -0,0 +1,21 @@
+package agent
+
+var RunFlagKey = struct {
+	ProfileName string
+}{
+	ProfileName: "profile-name",
+}
+
+var StartFlagKey = struct {
+	ProfileName string
+}{
+	ProfileName: "profile-name",
+}
+
+var SystemdUnitFlagKey = struct {
+	ProfileName string
+	Install     string
+}{
+	ProfileName: "profile-name",
+	Install:     "install",
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hunoz/maroon/agent"
+	"github.com/hunoz/maroon/cmd/credentials"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// refreshMargin is added to the refresh window of each profile for the agent, so it refreshes credentials before
+// 'credentials print' stops accepting them and SDK clients never have to refresh them themselves
+const refreshMargin = 5 * time.Minute
+
+var AgentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep profile credentials fresh in a background agent",
+	Long: "The Maroon agent holds credentials for chosen profiles in memory, refreshes them ahead of expiry and serves them " +
+		"over a socket only the current user can access. 'maroon credentials print' asks a running agent first, so AWS SDK " +
+		"clients get credentials without calling the Maroon API themselves. Set a token refresh command that does not need to " +
+		"prompt, since the agent runs longer than a token is valid.",
+}
+
+func init() {
+	AgentCmd.AddCommand(RunCmd, StartCmd, StopCmd, StatusCmd, SystemdUnitCmd)
+}
+
+// newAgentClient returns a client for the agent socket
+func newAgentClient() (*agent.Client, error) {
+	socketPath, err := agent.GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	return agent.NewClient(socketPath), nil
+}
+
+// validateProfiles checks that every profile the agent should hold exists and returns how long before expiry the
+// agent refreshes the credentials of each
+func validateProfiles(profiles []string) (map[string]time.Duration, error) {
+	if len(profiles) == 0 {
+		return nil, errors.New("At least one profile must be passed with '--profile-name'")
+	}
+
+	refreshBefore := map[string]time.Duration{}
+	for _, profileName := range profiles {
+		profile, err := config.GetProfile(profileName)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Profile '%s'", profileName))
+		}
+
+		if refreshBefore[profileName], err = refreshWindow(profile); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Profile '%s'", profileName))
+		}
+	}
+
+	return refreshBefore, nil
+}
+
+// refreshWindow returns how long before expiry the agent refreshes the credentials of a profile
+func refreshWindow(profile *config.Profile) (time.Duration, error) {
+	options, err := credentials.GetSessionOptions(profile)
+	if err != nil {
+		return 0, err
+	}
+
+	if options.RefreshBefore+refreshMargin < options.Duration {
+		return options.RefreshBefore + refreshMargin, nil
+	}
+	return options.RefreshBefore, nil
+}
+
+// agentRunArgs returns the arguments that run the agent in the foreground for profiles, along with the global flags
+// passed to this command. Tokens passed on the command line are not forwarded since they would be visible to other
+// users in the process list.
+func agentRunArgs(cmd *cobra.Command, profiles []string) []string {
+	args := []string{"agent", "run"}
+	for _, profileName := range profiles {
+		args = append(args, "--"+RunFlagKey.ProfileName, profileName)
+	}
+
+	cmd.Root().PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if !flag.Changed || flag.Name == global.FlagKey.Token || flag.Name == global.FlagKey.TokenStdin {
+			return
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", flag.Name, flag.Value.String()))
+	})
+
+	return args
+}
+
+// waitForAgent polls the agent until running reports the wanted state or the timeout passes
+func waitForAgent(client *agent.Client, running bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		_, err := client.Status()
+		if running && err == nil || !running && errors.Is(err, agent.ErrNotRunning) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// executable returns the path of the running Maroon binary
+func executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", errors.Wrap(err, "Cannot find the Maroon executable")
+	}
+	return exe, nil
+}
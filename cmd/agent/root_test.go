@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/spf13/cobra"
+)
+
+func TestAgentRunArgs(t *testing.T) {
+	root := &cobra.Command{Use: "maroon"}
+	root.PersistentFlags().String(global.FlagKey.Token, "", "")
+	root.PersistentFlags().Bool(global.FlagKey.TokenStdin, false, "")
+	root.PersistentFlags().String(global.FlagKey.TokenFile, "", "")
+	root.PersistentFlags().String(global.FlagKey.Context, "", "")
+	cmd := &cobra.Command{Use: "start"}
+	root.AddCommand(cmd)
+
+	root.PersistentFlags().Set(global.FlagKey.Token, "secret")
+	root.PersistentFlags().Set(global.FlagKey.TokenStdin, "true")
+	root.PersistentFlags().Set(global.FlagKey.TokenFile, "/run/token")
+
+	got := agentRunArgs(cmd, []string{"dev", "prod"})
+	want := []string{"agent", "run", "--profile-name", "dev", "--profile-name", "prod", "--token-file=/run/token"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("agentRunArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"/usr/local/bin/maroon", "/usr/local/bin/maroon"},
+		{"--token-command=spark token", `"--token-command=spark token"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\maroon`, `"C:\\maroon"`},
+		{"100%", "100%%"},
+		{"$HOME", "$$HOME"},
+		{"", `""`},
+	}
+
+	for _, test := range tests {
+		if got := systemdQuote(test.arg); got != test.want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", test.arg, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/agent"
+	"github.com/hunoz/maroon/cmd/credentials"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var RunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the Maroon agent in the foreground",
+	Long:  "Run the Maroon agent in the foreground, logging to stderr. This is what 'maroon agent start' and the systemd unit run.",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(RunFlagKey.ProfileName, cmd.Flags().Lookup(RunFlagKey.ProfileName))
+		// Nobody is around to answer a prompt while the agent runs
+		viper.Set(global.FlagKey.NoInput, true)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := viper.GetStringSlice(RunFlagKey.ProfileName)
+		refreshBefore, err := validateProfiles(profiles)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		socketPath, err := agent.GetSocketPath()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		listener, err := agent.Listen(socketPath)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+		defer listener.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		server := &agent.Server{
+			Profiles:      profiles,
+			Resolve:       credentials.ResolveCredentials,
+			RefreshBefore: func(profileName string) time.Duration { return currentRefreshWindow(profileName, refreshBefore) },
+			RetryDelay:    agent.DefaultRetryDelay,
+			Logf:          log.New(os.Stderr, "maroon-agent: ", log.LstdFlags).Printf,
+		}
+		if err = server.Serve(ctx, listener); err != nil {
+			color.Red("Maroon agent stopped: %v", err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RunCmd.Flags().StringSliceP(RunFlagKey.ProfileName, "p", nil, "Profile to keep credentials for. May be repeated")
+	RunCmd.MarkFlagRequired(RunFlagKey.ProfileName)
+}
+
+// currentRefreshWindow reads the profile again, so a shorter session negotiated since the agent started is taken into
+// account. The window computed at startup is used if the profile cannot be read.
+func currentRefreshWindow(profileName string, atStartup map[string]time.Duration) time.Duration {
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return atStartup[profileName]
+	}
+
+	profile, ok := configuration.Profiles[profileName]
+	if !ok {
+		return atStartup[profileName]
+	}
+
+	window, err := refreshWindow(&profile)
+	if err != nil {
+		return atStartup[profileName]
+	}
+	return window
+}
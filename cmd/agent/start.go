@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/agent"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var StartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the Maroon agent in the background",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(StartFlagKey.ProfileName, cmd.Flags().Lookup(StartFlagKey.ProfileName))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := viper.GetStringSlice(StartFlagKey.ProfileName)
+		if _, err := validateProfiles(profiles); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		client, err := newAgentClient()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if status, err := client.Status(); err == nil {
+			color.Red("Maroon agent is already running with pid %d. Run 'maroon agent stop' first", status.Pid)
+			os.Exit(1)
+		}
+
+		exe, err := executable()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		logPath, err := agent.GetLogPath()
+		if err == nil {
+			err = os.MkdirAll(filepath.Dir(logPath), 0700)
+		}
+		if err != nil {
+			color.Red("Cannot create agent log: %v", err.Error())
+			os.Exit(1)
+		}
+
+		logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			color.Red("Cannot open agent log: %v", err.Error())
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		process := exec.Command(exe, agentRunArgs(cmd, profiles)...)
+		process.Stdout = logFile
+		process.Stderr = logFile
+		process.SysProcAttr = detachedProcAttr()
+		process.Env = os.Environ()
+		if viper.IsSet(global.FlagKey.Token) {
+			process.Env = append(process.Env, global.EnvKey.Token+"="+viper.GetString(global.FlagKey.Token))
+		}
+
+		if err = process.Start(); err != nil {
+			color.Red("Cannot start Maroon agent: %v", err.Error())
+			os.Exit(1)
+		}
+		pid := process.Process.Pid
+		process.Process.Release()
+
+		if !waitForAgent(client, true, 10*time.Second) {
+			color.Red("Maroon agent did not start. See '%s' for details", logPath)
+			os.Exit(1)
+		}
+
+		color.Green("Started Maroon agent with pid %d for profiles %v. Logging to '%s'", pid, profiles, logPath)
+	},
+}
+
+func init() {
+	StartCmd.Flags().StringSliceP(StartFlagKey.ProfileName, "p", nil, "Profile to keep credentials for. May be repeated")
+	StartCmd.MarkFlagRequired(StartFlagKey.ProfileName)
+}
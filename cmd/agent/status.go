@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/spf13/cobra"
+)
+
+var StatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the Maroon agent is running and the credentials it holds",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newAgentClient()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		status, err := client.Status()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		err = global.PrintOutput(status, global.OutputText, func(w io.Writer) {
+			fmt.Fprintf(w, "Maroon agent is running with pid %d on '%s', up %v\n", status.Pid, status.Socket, time.Since(status.Started).Round(time.Second))
+			for _, profile := range status.Profiles {
+				if profile.Expiration != nil {
+					remaining := time.Until(*profile.Expiration).Round(time.Second)
+					if remaining > 0 {
+						fmt.Fprintf(w, "  %s: credentials expire at %s (in %v)\n", profile.Name, profile.Expiration.Local().Format(time.RFC1123), remaining)
+					} else {
+						fmt.Fprintf(w, "  %s: credentials expired at %s\n", profile.Name, profile.Expiration.Local().Format(time.RFC1123))
+					}
+				} else {
+					fmt.Fprintf(w, "  %s: no credentials yet\n", profile.Name)
+				}
+
+				if profile.Error != "" {
+					fmt.Fprintf(w, "    last refresh failed, retrying at %s: %s\n", profile.NextRetry.Local().Format(time.RFC1123), profile.Error)
+				}
+			}
+		})
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/agent"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var StopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running Maroon agent",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newAgentClient()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if err = client.Stop(); errors.Is(err, agent.ErrNotRunning) {
+			color.Red(err.Error())
+			os.Exit(1)
+		} else if err != nil {
+			color.Red("Error stopping Maroon agent: %v", err.Error())
+			os.Exit(1)
+		}
+
+		if !waitForAgent(client, false, 10*time.Second) {
+			color.Red("Maroon agent did not stop in time")
+			os.Exit(1)
+		}
+
+		color.Green("Stopped Maroon agent")
+	},
+}
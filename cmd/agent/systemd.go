@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/spark/homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const systemdUnitName = "maroon-agent.service"
+
+var SystemdUnitCmd = &cobra.Command{
+	Use:   "systemd-unit",
+	Short: "Generate a systemd user unit that runs the Maroon agent",
+	Long: "Print a systemd user unit that runs the Maroon agent for the given profiles, or write it to " +
+		"~/.config/systemd/user/" + systemdUnitName + " with '--install'. Global flags passed to this command are passed to the agent, " +
+		"environment variables are not.",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(SystemdUnitFlagKey.ProfileName, cmd.Flags().Lookup(SystemdUnitFlagKey.ProfileName))
+		viper.BindPFlag(SystemdUnitFlagKey.Install, cmd.Flags().Lookup(SystemdUnitFlagKey.Install))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles := viper.GetStringSlice(SystemdUnitFlagKey.ProfileName)
+		if _, err := validateProfiles(profiles); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if cmd.Flags().Changed(global.FlagKey.Token) || cmd.Flags().Changed(global.FlagKey.TokenStdin) {
+			color.Red("Tokens cannot be stored in a systemd unit. Use '--%s', '--%s' or Spark instead", global.FlagKey.TokenFile, global.FlagKey.TokenCommand)
+			os.Exit(1)
+		}
+
+		exe, err := executable()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		execStart := []string{systemdQuote(exe)}
+		for _, arg := range agentRunArgs(cmd, profiles) {
+			execStart = append(execStart, systemdQuote(arg))
+		}
+
+		unit := fmt.Sprintf(`[Unit]
+Description=Maroon credentials agent
+
+[Service]
+ExecStart=%s
+Restart=on-failure
+RestartSec=10
+
+[Install]
+WantedBy=default.target
+`, strings.Join(execStart, " "))
+
+		if !viper.GetBool(SystemdUnitFlagKey.Install) {
+			fmt.Print(unit)
+			return
+		}
+
+		home, err := homedir.Dir()
+		if err != nil {
+			color.Red("Unable to find home folder: %v", err.Error())
+			os.Exit(1)
+		}
+
+		unitPath := filepath.Join(home, ".config", "systemd", "user", systemdUnitName)
+		if err = os.MkdirAll(filepath.Dir(unitPath), 0755); err == nil {
+			err = os.WriteFile(unitPath, []byte(unit), 0644)
+		}
+		if err != nil {
+			color.Red("Cannot write systemd unit: %v", err.Error())
+			os.Exit(1)
+		}
+
+		color.Green("Wrote '%s'. Enable it with 'systemctl --user daemon-reload && systemctl --user enable --now %s'", unitPath, systemdUnitName)
+	},
+}
+
+// systemdQuote quotes an ExecStart argument so systemd passes it through unchanged
+func systemdQuote(arg string) string {
+	arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func init() {
+	SystemdUnitCmd.Flags().StringSliceP(SystemdUnitFlagKey.ProfileName, "p", nil, "Profile to keep credentials for. May be repeated")
+	SystemdUnitCmd.MarkFlagRequired(SystemdUnitFlagKey.ProfileName)
+	SystemdUnitCmd.Flags().Bool(SystemdUnitFlagKey.Install, false, "Write the unit to the systemd user unit folder instead of printing it")
+}
@@ -0,0 +1,127 @@
+package configcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewCachePassphraseEnv holds the new passphrase when rotating a passphrase protected cache
+const NewCachePassphraseEnv = "MAROON_NEW_CACHE_PASSPHRASE"
+
+var CacheStorageCmd = &cobra.Command{
+	Use:   "cache-storage",
+	Short: "Choose whether cached credentials are stored in plaintext or encrypted",
+	Long: fmt.Sprintf("Choose whether cached credentials are stored in plaintext or encrypted. Encrypted entries are sealed with AES-GCM "+
+		"using a key derived from %s when it is set, otherwise using a key file that is created on first use. "+
+		"Existing cache entries are rewritten in the chosen format", config.CachePassphraseEnv),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(CacheStorageFlagKey.Storage, cmd.Flags().Lookup(CacheStorageFlagKey.Storage))
+		viper.BindPFlag(CacheStorageFlagKey.KeyFile, cmd.Flags().Lookup(CacheStorageFlagKey.KeyFile))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		storage := viper.GetString(CacheStorageFlagKey.Storage)
+		if storage != config.CacheStoragePlaintext && storage != config.CacheStorageEncrypted {
+			color.Red("Storage '%s' is not valid. Storage must be one of '%s', '%s'", storage, config.CacheStoragePlaintext, config.CacheStorageEncrypted)
+			os.Exit(1)
+		}
+
+		current, err := config.GetCacheConfig()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		// The current key is only needed if entries are already encrypted
+		oldKey, _ := config.LoadCacheKey(current, false)
+
+		updated := config.CacheConfig{Storage: storage}
+		if cmd.Flags().Changed(CacheStorageFlagKey.KeyFile) {
+			updated.KeyFile = viper.GetString(CacheStorageFlagKey.KeyFile)
+		} else if raw, err := config.GetConfig(); err == nil && raw.Cache != nil {
+			updated.KeyFile = raw.Cache.KeyFile
+		}
+
+		var newKey *config.CacheKey
+		if storage == config.CacheStorageEncrypted {
+			withDefaults := updated
+			if withDefaults.KeyFile == "" {
+				withDefaults.KeyFile = current.KeyFile
+			}
+			if newKey, err = config.LoadCacheKey(&withDefaults, true); err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		if err = config.RewriteCredentialCache(oldKey, newKey); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if err = config.UpdateCacheConfig(updated); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		color.Green("Cached credentials are now stored %s", map[string]string{
+			config.CacheStoragePlaintext: "in plaintext",
+			config.CacheStorageEncrypted: "encrypted",
+		}[storage])
+	},
+}
+
+var RotateCacheKeyCmd = &cobra.Command{
+	Use:   "rotate-cache-key",
+	Short: "Re-encrypt cached credentials with a new key",
+	Long: fmt.Sprintf("Re-encrypt cached credentials with a new key. When the cache is protected by a key file, a new random key "+
+		"replaces it and the previous key is kept next to it with a '.old' suffix. When it is protected by %s, the new "+
+		"passphrase is read from %s", config.CachePassphraseEnv, NewCachePassphraseEnv),
+	Run: func(cmd *cobra.Command, args []string) {
+		cacheConfig, err := config.GetCacheConfig()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		} else if cacheConfig.Storage != config.CacheStorageEncrypted {
+			color.Red("Cached credentials are not encrypted. Run 'maroon config cache-storage --storage %s' first", config.CacheStorageEncrypted)
+			os.Exit(1)
+		}
+
+		oldKey, err := config.LoadCacheKey(cacheConfig, false)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if oldKey.Passphrase != "" {
+			newPassphrase := os.Getenv(NewCachePassphraseEnv)
+			if newPassphrase == "" {
+				color.Red("Set %s to the new passphrase", NewCachePassphraseEnv)
+				os.Exit(1)
+			}
+			err = config.RewriteCredentialCache(oldKey, &config.CacheKey{Passphrase: newPassphrase})
+		} else {
+			err = config.RotateCacheKeyFile(cacheConfig)
+		}
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if oldKey.Passphrase != "" {
+			color.Green("Rotated cache passphrase. Set %s to the new passphrase from now on", config.CachePassphraseEnv)
+		} else {
+			color.Green("Rotated cache key")
+		}
+	},
+}
+
+func init() {
+	CacheStorageCmd.Flags().StringP(CacheStorageFlagKey.Storage, "s", "", fmt.Sprintf("Storage for cached credentials. Must be one of '%s', '%s'", config.CacheStoragePlaintext, config.CacheStorageEncrypted))
+	CacheStorageCmd.MarkFlagRequired(CacheStorageFlagKey.Storage)
+	CacheStorageCmd.Flags().StringP(CacheStorageFlagKey.KeyFile, "k", "", "Path to the key file used when no passphrase is set. Defaults to cache.key next to the Maroon config")
+}
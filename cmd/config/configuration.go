@@ -0,0 +1,17 @@
+package configcmd
+
+var CacheStorageFlagKey = struct {
+	Storage string
+	KeyFile string
+}{
+	Storage: "storage",
+	KeyFile: "key-file",
+}
+
+var RestoreFlagKey = struct {
+	File   string
+	Backup string
+}{
+	File:   "file",
+	Backup: "backup",
+}
@@ -0,0 +1,88 @@
+package configcmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const backupTimeLayout = "2006-01-02 15:04:05.000 MST"
+
+type backupListing struct {
+	Backup  int       `json:"backup"`
+	Created time.Time `json:"created"`
+	Path    string    `json:"path"`
+}
+
+var RestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Roll back a file managed by Maroon to a backup",
+	Long: fmt.Sprintf("Roll back the Maroon config (%s), the AWS config (%s) or the AWS credentials file (%s) to one of the last %d "+
+		"versions Maroon replaced. Without '--backup', the available backups are listed. The current version is backed up "+
+		"before it is restored over, so a restore can be undone", config.ManagedConfig, config.ManagedAwsConfig, config.ManagedAwsCredentials, config.BackupCount),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(RestoreFlagKey.File, cmd.Flags().Lookup(RestoreFlagKey.File))
+		viper.BindPFlag(RestoreFlagKey.Backup, cmd.Flags().Lookup(RestoreFlagKey.Backup))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := config.GetManagedFile(viper.GetString(RestoreFlagKey.File))
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		backups, err := config.ListBackups(file)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		} else if len(backups) == 0 {
+			color.Red("There are no backups of '%s'", file.Path)
+			os.Exit(1)
+		}
+
+		if !cmd.Flags().Changed(RestoreFlagKey.Backup) {
+			listings := make([]backupListing, len(backups))
+			for i, backup := range backups {
+				listings[i] = backupListing{Backup: i + 1, Created: backup.Created, Path: backup.Path}
+			}
+
+			err = global.PrintOutput(listings, global.OutputText, func(w io.Writer) {
+				fmt.Fprintf(w, "Backups of '%s', newest first:\n", file.Path)
+				for _, listing := range listings {
+					fmt.Fprintf(w, "  %d: %s\n", listing.Backup, listing.Created.Local().Format(backupTimeLayout))
+				}
+			})
+			if err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+
+		index := viper.GetInt(RestoreFlagKey.Backup)
+		if index < 1 || index > len(backups) {
+			color.Red("Backup must be between 1 and %d", len(backups))
+			os.Exit(1)
+		}
+
+		backup := backups[index-1]
+		if err = config.RestoreBackup(file, backup); err != nil {
+			color.Red("Error restoring backup: %s", err.Error())
+			os.Exit(1)
+		}
+
+		color.Green("Restored '%s' to the version from %s", file.Path, backup.Created.Local().Format(backupTimeLayout))
+	},
+}
+
+func init() {
+	RestoreCmd.Flags().StringP(RestoreFlagKey.File, "f", config.ManagedConfig, fmt.Sprintf("File to restore. Must be one of '%s', '%s', '%s'", config.ManagedConfig, config.ManagedAwsConfig, config.ManagedAwsCredentials))
+	RestoreCmd.Flags().IntP(RestoreFlagKey.Backup, "b", 0, "Backup to restore, as numbered in the list of backups. 1 is the newest")
+}
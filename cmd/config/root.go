@@ -0,0 +1,14 @@
+package configcmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the Maroon config, its backups and the credential cache",
+}
+
+func init() {
+	ConfigCmd.AddCommand(CacheStorageCmd, RotateCacheKeyCmd, RestoreCmd)
+}
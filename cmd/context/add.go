@@ -0,0 +1,66 @@
+package context
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var AddContextCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a Maroon API context",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(AddContextFlagKey.Name, cmd.Flags().Lookup(AddContextFlagKey.Name))
+		viper.BindPFlag(AddContextFlagKey.ApiUrl, cmd.Flags().Lookup(AddContextFlagKey.ApiUrl))
+		viper.BindPFlag(AddContextFlagKey.ApiPathPrefix, cmd.Flags().Lookup(AddContextFlagKey.ApiPathPrefix))
+		viper.BindPFlag(AddContextFlagKey.IdentitySource, cmd.Flags().Lookup(AddContextFlagKey.IdentitySource))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		contextName := viper.GetString(AddContextFlagKey.Name)
+		apiUrl := viper.GetString(AddContextFlagKey.ApiUrl)
+		apiPathPrefix := viper.GetString(AddContextFlagKey.ApiPathPrefix)
+		// An empty prefix is stored as '/', since an unset prefix means the default one
+		if apiPathPrefix == "" {
+			apiPathPrefix = "/"
+		}
+		identitySource := viper.GetString(AddContextFlagKey.IdentitySource)
+		if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(contextName) {
+			color.Red("Context name '%s' is not allowed. Context name must only contain alphanumeric characters and the following special characters: '-'", contextName)
+			os.Exit(1)
+		} else if _, err := config.JoinApiUrl(apiUrl, apiPathPrefix); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if identitySource != "" {
+			if _, err := config.GetCognitoConfigFromFile(identitySource); err != nil {
+				color.Red("Identity source '%s' is not a readable Spark config: %v", identitySource, err.Error())
+				os.Exit(1)
+			}
+		}
+
+		err := config.AddContext(contextName, config.ApiContext{
+			Endpoint:       apiUrl,
+			ApiPathPrefix:  apiPathPrefix,
+			IdentitySource: identitySource,
+		})
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+		color.Green("Added context '%s'", contextName)
+	},
+}
+
+func init() {
+	AddContextCmd.Flags().StringP(AddContextFlagKey.Name, "n", "", "Name to give the context. Must only contain alphanumeric characters and the following special characters: '-'")
+	AddContextCmd.MarkFlagRequired(AddContextFlagKey.Name)
+	AddContextCmd.Flags().StringP(AddContextFlagKey.ApiUrl, "u", "", "Base URL of the Maroon API (i.e. https://api.maroon.example.com)")
+	AddContextCmd.MarkFlagRequired(AddContextFlagKey.ApiUrl)
+	AddContextCmd.Flags().String(AddContextFlagKey.ApiPathPrefix, config.DefaultApiPathPrefix, "Path prefix that Maroon API operations are served under. Use '/' for an API served at the root of its URL")
+	AddContextCmd.Flags().StringP(AddContextFlagKey.IdentitySource, "s", "", "Path to the Spark config holding the Cognito token for this deployment. Defaults to Spark's own config")
+}
@@ -0,0 +1,25 @@
+package context
+
+var AddContextFlagKey = struct {
+	Name           string
+	ApiUrl         string
+	ApiPathPrefix  string
+	IdentitySource string
+}{
+	Name:           "name",
+	ApiUrl:         "api-url",
+	ApiPathPrefix:  "api-path-prefix",
+	IdentitySource: "identity-source",
+}
+
+var UseContextFlagKey = struct {
+	Name string
+}{
+	Name: "name",
+}
+
+var RemoveContextFlagKey = struct {
+	Name string
+}{
+	Name: "name",
+}
@@ -0,0 +1,73 @@
+package context
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+)
+
+type contextListing struct {
+	Name           string `json:"name"`
+	Current        bool   `json:"current"`
+	ApiUrl         string `json:"apiUrl"`
+	IdentitySource string `json:"identitySource"`
+}
+
+var ListContextCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the contexts in the Maroon config",
+	Run: func(cmd *cobra.Command, args []string) {
+		configuration, err := config.GetConfig()
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if len(configuration.Contexts) == 0 {
+			color.Cyan("No contexts configured. Add one with 'maroon context add'")
+		}
+
+		names := make([]string, 0, len(configuration.Contexts))
+		for name := range configuration.Contexts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		contexts := []contextListing{}
+		for _, name := range names {
+			apiContext := configuration.Contexts[name]
+			identitySource := apiContext.IdentitySource
+			if identitySource == "" {
+				identitySource = "spark"
+			}
+
+			apiUrl, _ := config.JoinApiUrl(apiContext.Endpoint, apiContext.ApiPathPrefix)
+			contexts = append(contexts, contextListing{
+				Name:           name,
+				Current:        name == configuration.CurrentContext,
+				ApiUrl:         apiUrl,
+				IdentitySource: identitySource,
+			})
+		}
+
+		err = global.PrintOutput(contexts, global.OutputText, func(w io.Writer) {
+			for _, listing := range contexts {
+				marker := " "
+				if listing.Current {
+					marker = "*"
+				}
+				fmt.Fprintf(w, "%s %s\t%s\t%s\n", marker, listing.Name, listing.ApiUrl, listing.IdentitySource)
+			}
+		})
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,34 @@
+package context
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var RemoveContextCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove a context from the Maroon config",
+	Long:  "Remove a context from the Maroon config. Contexts that are still used by a profile cannot be removed",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(RemoveContextFlagKey.Name, cmd.Flags().Lookup(RemoveContextFlagKey.Name))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		contextName := viper.GetString(RemoveContextFlagKey.Name)
+
+		if err := config.RemoveContext(contextName); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		color.Green("Removed context '%s'", contextName)
+	},
+}
+
+func init() {
+	RemoveContextCmd.Flags().StringP(RemoveContextFlagKey.Name, "n", "", "Context name to remove")
+	RemoveContextCmd.MarkFlagRequired(RemoveContextFlagKey.Name)
+}
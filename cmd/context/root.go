@@ -0,0 +1,14 @@
+package context
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var ContextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage the Maroon API deployments that profiles can target",
+}
+
+func init() {
+	ContextCmd.AddCommand(ListContextCmd, UseContextCmd, AddContextCmd, RemoveContextCmd)
+}
@@ -0,0 +1,33 @@
+package context
+
+import (
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var UseContextCmd = &cobra.Command{
+	Use:   "use",
+	Short: "Set the context used by profiles and commands that do not specify one",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(UseContextFlagKey.Name, cmd.Flags().Lookup(UseContextFlagKey.Name))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		contextName := viper.GetString(UseContextFlagKey.Name)
+
+		if err := config.UseContext(contextName); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		color.Green("Switched to context '%s'", contextName)
+	},
+}
+
+func init() {
+	UseContextCmd.Flags().StringP(UseContextFlagKey.Name, "n", "", "Context name to use")
+	UseContextCmd.MarkFlagRequired(UseContextFlagKey.Name)
+}
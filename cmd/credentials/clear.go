@@ -0,0 +1,220 @@
+package credentials
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/agent"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Places cleared credentials were removed from
+const (
+	clearedFromCache          = "cache"
+	clearedFromAwsCredentials = config.ManagedAwsCredentials
+)
+
+// clearedCredentials describes credentials removed by the clear command
+type clearedCredentials struct {
+	ProfileName string     `json:"profileName"`
+	Location    string     `json:"location"`
+	Section     string     `json:"section,omitempty"`
+	AccessKeyId string     `json:"accessKeyId,omitempty"`
+	Expiration  *time.Time `json:"expiration,omitempty"`
+}
+
+var ClearCredentialsCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove cached credentials and the sections Maroon wrote to the AWS credentials file",
+	Long: "Remove the cached credentials of one or more profiles, or of every profile with '--all', together with the " +
+		"sections Maroon wrote for them to the AWS credentials file. Sections Maroon did not write are never touched. " +
+		"With '--expired', only credentials that have already expired are removed. With '--reset-max-session-duration', the " +
+		"session duration limit learned from each profile's role is forgotten too, so the configured duration is requested again.",
+	Example: "  maroon credentials clear -p dev -p prod\n" +
+		"  maroon credentials clear --all\n" +
+		"  maroon credentials clear -p prod --reset-max-session-duration\n" +
+		"  maroon credentials clear --all --expired",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(ClearFlagKey.ProfileName, cmd.Flags().Lookup(ClearFlagKey.ProfileName))
+		viper.BindPFlag(ClearFlagKey.All, cmd.Flags().Lookup(ClearFlagKey.All))
+		viper.BindPFlag(ClearFlagKey.Expired, cmd.Flags().Lookup(ClearFlagKey.Expired))
+		viper.BindPFlag(ClearFlagKey.ResetMaxSessionDuration, cmd.Flags().Lookup(ClearFlagKey.ResetMaxSessionDuration))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		all := viper.GetBool(ClearFlagKey.All)
+		expiredOnly := viper.GetBool(ClearFlagKey.Expired)
+
+		profileNames := viper.GetStringSlice(ClearFlagKey.ProfileName)
+		if all {
+			var err error
+			if profileNames, err = clearableProfiles(); err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+		} else if len(profileNames) == 0 {
+			color.Red("Pass the profiles to clear with '--%s' or clear every profile with '--%s'", ClearFlagKey.ProfileName, ClearFlagKey.All)
+			os.Exit(1)
+		}
+
+		selected := map[string]bool{}
+		for _, profileName := range profileNames {
+			if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
+				color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
+				os.Exit(1)
+			}
+			selected[profileName] = true
+		}
+
+		cleared := []clearedCredentials{}
+		for _, profileName := range profileNames {
+			credentials, removed, err := config.ClearCachedCredentials(profileName, expiredOnly)
+			if err != nil {
+				color.Red("Error clearing cached credentials for profile '%s': %s", profileName, err.Error())
+				os.Exit(1)
+			} else if removed {
+				cleared = append(cleared, clearedCredentials{
+					ProfileName: profileName,
+					Location:    clearedFromCache,
+					AccessKeyId: maskAccessKeyId(credentials.AccessKeyId),
+					Expiration:  credentials.Expiration,
+				})
+			}
+		}
+
+		if viper.GetBool(ClearFlagKey.ResetMaxSessionDuration) {
+			for _, profileName := range profileNames {
+				reset, err := config.ResetMaxSessionDuration(profileName)
+				if err != nil {
+					color.Red("Error resetting the session duration limit of profile '%s': %s", profileName, err.Error())
+					os.Exit(1)
+				} else if reset {
+					color.Cyan("Forgot the session duration limit learned for profile '%s'", profileName)
+				}
+			}
+		}
+
+		sections, err := config.RemoveAwsCredentialsSections(func(section config.AwsCredentialsSection) bool {
+			if !all && !selected[section.ProfileName] {
+				return false
+			}
+			return !expiredOnly || (section.Credentials.Expiration != nil && section.Credentials.Expiration.Before(time.Now()))
+		})
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+		for _, section := range sections {
+			cleared = append(cleared, clearedCredentials{
+				ProfileName: section.ProfileName,
+				Location:    clearedFromAwsCredentials,
+				Section:     section.Name,
+				AccessKeyId: maskAccessKeyId(section.Credentials.AccessKeyId),
+				Expiration:  section.Credentials.Expiration,
+			})
+		}
+
+		if len(cleared) == 0 {
+			color.Cyan("There are no credentials to remove")
+		}
+		warnAgentHolds(cleared)
+
+		err = global.PrintOutput(cleared, global.OutputText, func(w io.Writer) {
+			for _, removed := range cleared {
+				details := removed.AccessKeyId
+				if removed.Expiration != nil {
+					details += ", expiration " + removed.Expiration.Local().Format(time.RFC1123)
+				}
+
+				if removed.Location == clearedFromCache {
+					fmt.Fprintf(w, "Removed cached credentials for profile '%s' (%s)\n", removed.ProfileName, details)
+				} else {
+					fmt.Fprintf(w, "Removed section '%s' of the AWS credentials file for profile '%s' (%s)\n", removed.Section, removed.ProfileName, details)
+				}
+			}
+		})
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+// clearableProfiles returns the profiles in the Maroon config and any other profiles with cached credentials
+func clearableProfiles() ([]string, error) {
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cached, err := config.ListCachedProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	unique := map[string]bool{}
+	for profileName := range configuration.Profiles {
+		unique[profileName] = true
+	}
+	for _, profileName := range cached {
+		unique[profileName] = true
+	}
+
+	profileNames := make([]string, 0, len(unique))
+	for profileName := range unique {
+		profileNames = append(profileNames, profileName)
+	}
+	sort.Strings(profileNames)
+
+	return profileNames, nil
+}
+
+// warnAgentHolds warns when a running Maroon agent still holds credentials for a cleared profile in memory
+func warnAgentHolds(cleared []clearedCredentials) {
+	socketPath, err := agent.GetSocketPath()
+	if err != nil {
+		return
+	}
+
+	status, err := agent.NewClient(socketPath).Status()
+	if err != nil {
+		return
+	}
+
+	for _, profile := range status.Profiles {
+		for _, removed := range cleared {
+			if removed.ProfileName == profile.Name && profile.Expiration != nil {
+				color.Yellow("The Maroon agent still holds credentials for profile '%s'. Run 'maroon agent stop' to discard them", profile.Name)
+				break
+			}
+		}
+	}
+}
+
+// maskAccessKeyId hides all but the first and last four characters of an access key ID
+func maskAccessKeyId(accessKeyId *string) string {
+	if accessKeyId == nil || *accessKeyId == "" {
+		return ""
+	} else if len(*accessKeyId) <= 8 {
+		return strings.Repeat("*", len(*accessKeyId))
+	}
+
+	key := *accessKeyId
+	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
+}
+
+func init() {
+	ClearCredentialsCmd.Flags().StringSliceP(ClearFlagKey.ProfileName, "p", nil, "Profiles to clear. May be repeated or comma separated")
+	ClearCredentialsCmd.Flags().Bool(ClearFlagKey.All, false, "Clear every profile")
+	ClearCredentialsCmd.Flags().Bool(ClearFlagKey.Expired, false, "Only remove credentials that have already expired")
+	ClearCredentialsCmd.Flags().Bool(ClearFlagKey.ResetMaxSessionDuration, false, "Also forget the session duration limit learned from the role, so the configured duration is requested again")
+	ClearCredentialsCmd.MarkFlagsMutuallyExclusive(ClearFlagKey.All, ClearFlagKey.ProfileName)
+}
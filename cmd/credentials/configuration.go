@@ -1,5 +1,13 @@
 package credentials
 
+var SessionFlagKey = struct {
+	Duration      string
+	RefreshBefore string
+}{
+	Duration:      "duration",
+	RefreshBefore: "refresh-before",
+}
+
 var PrintFlagKey = struct {
 	ProfileName string
 }{
@@ -8,6 +16,54 @@ var PrintFlagKey = struct {
 
 var UpdateFlagKey = struct {
 	ProfileName string
+	Target      string
+	All         string
+	Force       string
+}{
+	ProfileName: "profile-name",
+	Target:      "target",
+	All:         "all",
+	Force:       "force",
+}
+
+var ExportFlagKey = struct {
+	ProfileName string
+	Shell       string
+	Unset       string
+}{
+	ProfileName: "profile-name",
+	Shell:       "shell",
+	Unset:       "unset",
+}
+
+var ClearFlagKey = struct {
+	ProfileName             string
+	All                     string
+	Expired                 string
+	ResetMaxSessionDuration string
+}{
+	ProfileName:             "profile-name",
+	All:                     "all",
+	Expired:                 "expired",
+	ResetMaxSessionDuration: "reset-max-session-duration",
+}
+
+var RevokeFlagKey = struct {
+	ProfileName string
+	AllSessions string
+	Yes         string
+}{
+	ProfileName: "profile-name",
+	AllSessions: "all-sessions",
+	Yes:         "yes",
+}
+
+var StatusFlagKey = struct {
+	ProfileName string
+	Verify      string
+	StsEndpoint string
 }{
 	ProfileName: "profile-name",
+	Verify:      "verify",
+	StsEndpoint: "sts-endpoint",
 }
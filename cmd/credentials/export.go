@@ -0,0 +1,207 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// EnvVar is an environment variable that hands credentials to AWS SDKs
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// CredentialsEnvVarNames are the variables set by CredentialsEnvVars, in order
+var CredentialsEnvVarNames = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_CREDENTIAL_EXPIRATION",
+	"AWS_REGION",
+	"AWS_DEFAULT_REGION",
+}
+
+// CredentialsEnvVars returns the environment variables that hand credentials and region to AWS SDKs. The region
+// variables are left out when region is empty.
+func CredentialsEnvVars(credentials types.Credentials, region string) []EnvVar {
+	env := []EnvVar{
+		{"AWS_ACCESS_KEY_ID", *credentials.AccessKeyId},
+		{"AWS_SECRET_ACCESS_KEY", *credentials.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", *credentials.SessionToken},
+		{"AWS_CREDENTIAL_EXPIRATION", credentials.Expiration.UTC().Format(time.RFC3339)},
+	}
+	if region != "" {
+		env = append(env, EnvVar{"AWS_REGION", region}, EnvVar{"AWS_DEFAULT_REGION", region})
+	}
+	return env
+}
+
+// exportFormat renders statements that set and unset environment variables for a shell or file format. File formats
+// can only set variables, since a line like NAME= sets an empty value, so their unset is nil.
+type exportFormat struct {
+	set   func(name string, value string) string
+	unset func(name string) string
+}
+
+var exportFormats = map[string]exportFormat{
+	"sh": {
+		set:   func(name, value string) string { return fmt.Sprintf("export %s=%s", name, quotePosix(value)) },
+		unset: func(name string) string { return "unset " + name },
+	},
+	"fish": {
+		set:   func(name, value string) string { return fmt.Sprintf("set -gx %s %s", name, quoteFish(value)) },
+		unset: func(name string) string { return "set -e " + name },
+	},
+	"powershell": {
+		set:   func(name, value string) string { return fmt.Sprintf("$Env:%s = %s", name, quotePowerShell(value)) },
+		unset: func(name string) string { return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", name) },
+	},
+	"dotenv": {
+		set: func(name, value string) string { return fmt.Sprintf("%s=%s", name, quoteDotenv(value)) },
+	},
+	"github": {
+		set: formatGitHubEnv,
+	},
+}
+
+// exportFormatAliases maps other accepted format names to the format they use
+var exportFormatAliases = map[string]string{
+	"bash": "sh",
+	"zsh":  "sh",
+	"pwsh": "powershell",
+}
+
+var ExportCredentialsCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print a profile's credentials as environment variables for a shell, .env file or CI",
+	Long: "Print a profile's credentials and region as environment variables. With '--shell', formats are 'sh' (also 'bash', 'zsh'), " +
+		"'fish', 'powershell' (also 'pwsh'), 'dotenv' for docker compose and 'github' for $GITHUB_ENV files. With '--unset', " +
+		"the statements that remove the variables again are printed instead. The 'dotenv' and 'github' formats can only " +
+		"set variables, so '--unset' is refused for them.",
+	Example: "  eval \"$(maroon credentials export -p dev)\"\n" +
+		"  maroon credentials export -p dev --shell fish | source\n" +
+		"  maroon credentials export -p dev --shell powershell | Invoke-Expression\n" +
+		"  maroon credentials export -p dev --shell github >> \"$GITHUB_ENV\"\n" +
+		"  eval \"$(maroon credentials export --unset)\"",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(ExportFlagKey.ProfileName, cmd.Flags().Lookup(ExportFlagKey.ProfileName))
+		viper.BindPFlag(ExportFlagKey.Shell, cmd.Flags().Lookup(ExportFlagKey.Shell))
+		viper.BindPFlag(ExportFlagKey.Unset, cmd.Flags().Lookup(ExportFlagKey.Unset))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		formatName := strings.ToLower(viper.GetString(ExportFlagKey.Shell))
+		if alias, ok := exportFormatAliases[formatName]; ok {
+			formatName = alias
+		}
+		format, ok := exportFormats[formatName]
+		if !ok {
+			color.Red("Format '%s' is not valid. Format must be one of 'sh', 'bash', 'zsh', 'fish', 'powershell', 'pwsh', 'dotenv', 'github'", viper.GetString(ExportFlagKey.Shell))
+			os.Exit(1)
+		}
+
+		if viper.GetBool(ExportFlagKey.Unset) {
+			statements, err := unsetStatements(formatName, format)
+			if err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+			for _, statement := range statements {
+				fmt.Println(statement)
+			}
+			return
+		}
+
+		profileName := viper.GetString(ExportFlagKey.ProfileName)
+		if profileName == "" {
+			color.Red("Pass the profile to export with '--%s'", ExportFlagKey.ProfileName)
+			os.Exit(1)
+		} else if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
+			color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
+			os.Exit(1)
+		}
+
+		profile, err := config.GetProfile(profileName)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		credentials := GetActiveCredentials(profileName)
+		for _, variable := range CredentialsEnvVars(credentials, profile.Region) {
+			fmt.Println(format.set(variable.Name, variable.Value))
+		}
+	},
+}
+
+// quotePosix single quotes a value for sh, bash and zsh
+func quotePosix(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish single quotes a value for fish, where backslashes and single quotes are escaped inside single quotes
+func quoteFish(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// quotePowerShell single quotes a value for PowerShell, where single quotes are doubled inside single quotes
+func quotePowerShell(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// quoteDotenv quotes a value for docker compose .env files when it contains anything but safe characters. Values
+// spanning lines are double quoted with escaped newlines.
+func quoteDotenv(value string) string {
+	if regexp.MustCompile(`^[A-Za-z0-9_./+=:@-]*$`).MatchString(value) {
+		return value
+	} else if !strings.ContainsAny(value, "'\r\n") {
+		return "'" + value + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\r", `\r`, "\n", `\n`).Replace(value) + `"`
+}
+
+// unsetStatements returns the statements that remove the exported variables in a format. Formats that can only set
+// variables are refused, since removing a line from a file is not something a statement can do.
+func unsetStatements(formatName string, format exportFormat) ([]string, error) {
+	if format.unset == nil {
+		return nil, errors.New(fmt.Sprintf("Format '%s' can only set variables, and NAME= would set them to empty values. Remove the lines from the file instead", formatName))
+	}
+
+	var statements []string
+	for _, name := range CredentialsEnvVarNames {
+		statements = append(statements, format.unset(name))
+	}
+	return statements, nil
+}
+
+// formatGitHubEnv writes a $GITHUB_ENV entry, using the delimiter syntax for values that span lines
+func formatGitHubEnv(name string, value string) string {
+	if !strings.ContainsAny(value, "\r\n") {
+		return name + "=" + value
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		color.Red("Cannot generate delimiter: %v", err.Error())
+		os.Exit(1)
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+	return fmt.Sprintf("%s<<%s\n%s\n%s", name, delimiter, value, delimiter)
+}
+
+func init() {
+	ExportCredentialsCmd.Flags().StringP(ExportFlagKey.ProfileName, "p", "", "Profile name")
+	ExportCredentialsCmd.Flags().StringP(ExportFlagKey.Shell, "s", "sh", "Shell or file format to print the variables for. Must be one of 'sh', 'bash', 'zsh', 'fish', 'powershell', 'pwsh', 'dotenv', 'github'")
+	ExportCredentialsCmd.Flags().Bool(ExportFlagKey.Unset, false, "Print the statements that remove the variables instead")
+}
@@ -0,0 +1,175 @@
+package credentials
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// exportTestValues hold the characters each format has to quote or escape
+var exportTestValues = []struct {
+	name  string
+	value string
+}{
+	{"plain", "ASIAEXAMPLE"},
+	{"equals", "abc/def+ghi=="},
+	{"single quote", "it's"},
+	{"backslash", `a\b`},
+	{"dollar", "$HOME"},
+	{"newline", "line1\nline2"},
+	{"everything", "a'b\\c$d\ne=f"},
+}
+
+func TestQuotePosix(t *testing.T) {
+	want := map[string]string{
+		"plain":        `'ASIAEXAMPLE'`,
+		"equals":       `'abc/def+ghi=='`,
+		"single quote": `'it'\''s'`,
+		"backslash":    `'a\b'`,
+		"dollar":       `'$HOME'`,
+		"newline":      "'line1\nline2'",
+		"everything":   "'a'\\''b\\c$d\ne=f'",
+	}
+	testQuote(t, quotePosix, want)
+}
+
+func TestQuoteFish(t *testing.T) {
+	want := map[string]string{
+		"plain":        `'ASIAEXAMPLE'`,
+		"equals":       `'abc/def+ghi=='`,
+		"single quote": `'it\'s'`,
+		"backslash":    `'a\\b'`,
+		"dollar":       `'$HOME'`,
+		"newline":      "'line1\nline2'",
+		"everything":   "'a\\'b\\\\c$d\ne=f'",
+	}
+	testQuote(t, quoteFish, want)
+}
+
+func TestQuotePowerShell(t *testing.T) {
+	want := map[string]string{
+		"plain":        `'ASIAEXAMPLE'`,
+		"equals":       `'abc/def+ghi=='`,
+		"single quote": `'it''s'`,
+		"backslash":    `'a\b'`,
+		"dollar":       `'$HOME'`,
+		"newline":      "'line1\nline2'",
+		"everything":   "'a''b\\c$d\ne=f'",
+	}
+	testQuote(t, quotePowerShell, want)
+}
+
+func TestQuoteDotenv(t *testing.T) {
+	want := map[string]string{
+		"plain":        `ASIAEXAMPLE`,
+		"equals":       `abc/def+ghi==`,
+		"single quote": `"it's"`,
+		"backslash":    `'a\b'`,
+		"dollar":       `'$HOME'`,
+		"newline":      `"line1\nline2"`,
+		"everything":   `"a'b\\c\$d\ne=f"`,
+	}
+	testQuote(t, quoteDotenv, want)
+}
+
+func TestFormatGitHubEnv(t *testing.T) {
+	delimited := regexp.MustCompile(`^(AWS_SESSION_TOKEN)<<(ghadelimiter_[0-9a-f]{32})\n([\s\S]*)\n(ghadelimiter_[0-9a-f]{32})$`)
+
+	for _, test := range exportTestValues {
+		t.Run(test.name, func(t *testing.T) {
+			got := formatGitHubEnv("AWS_SESSION_TOKEN", test.value)
+
+			if !strings.Contains(test.value, "\n") {
+				if want := "AWS_SESSION_TOKEN=" + test.value; got != want {
+					t.Errorf("formatGitHubEnv() = %q, want %q", got, want)
+				}
+				return
+			}
+
+			match := delimited.FindStringSubmatch(got)
+			if match == nil {
+				t.Fatalf("formatGitHubEnv() = %q, want the delimiter syntax", got)
+			}
+			if match[2] != match[4] {
+				t.Errorf("opening delimiter %q does not match closing delimiter %q", match[2], match[4])
+			}
+			if match[3] != test.value {
+				t.Errorf("value = %q, want %q", match[3], test.value)
+			}
+		})
+	}
+}
+
+func TestUnsetStatements(t *testing.T) {
+	tests := []struct {
+		format    string
+		wantFirst string
+		wantError bool
+	}{
+		{"sh", "unset AWS_ACCESS_KEY_ID", false},
+		{"fish", "set -e AWS_ACCESS_KEY_ID", false},
+		{"powershell", "Remove-Item Env:AWS_ACCESS_KEY_ID -ErrorAction SilentlyContinue", false},
+		{"dotenv", "", true},
+		{"github", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			statements, err := unsetStatements(test.format, exportFormats[test.format])
+			if test.wantError {
+				if err == nil {
+					t.Errorf("unsetStatements() = %q, want an error for a format that can only set variables", statements)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(statements) != len(CredentialsEnvVarNames) {
+				t.Fatalf("got %d statements, want one for each of %v", len(statements), CredentialsEnvVarNames)
+			}
+			if statements[0] != test.wantFirst {
+				t.Errorf("first statement = %q, want %q", statements[0], test.wantFirst)
+			}
+			for i, name := range CredentialsEnvVarNames {
+				if !strings.Contains(statements[i], name) || strings.Contains(statements[i], "=") {
+					t.Errorf("statement %q does not remove %s", statements[i], name)
+				}
+			}
+		})
+	}
+}
+
+// TestQuotePosixRoundTrip checks that sh reads back exactly the exported value
+func TestQuotePosixRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+
+	for _, test := range exportTestValues {
+		t.Run(test.name, func(t *testing.T) {
+			script := exportFormats["sh"].set("MAROON_TEST_VALUE", test.value) + `; printf '%s' "$MAROON_TEST_VALUE"`
+			output, err := exec.Command(sh, "-c", script).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != test.value {
+				t.Errorf("sh read back %q, want %q", output, test.value)
+			}
+		})
+	}
+}
+
+func testQuote(t *testing.T, quote func(string) string, want map[string]string) {
+	t.Helper()
+
+	for _, test := range exportTestValues {
+		t.Run(test.name, func(t *testing.T) {
+			if got := quote(test.value); got != want[test.name] {
+				t.Errorf("quote(%q) = %q, want %q", test.value, got, want[test.name])
+			}
+		})
+	}
+}
@@ -1,85 +1,97 @@
 package credentials
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
 	"github.com/fatih/color"
 	v1 "github.com/hunoz/maroon-api/api/v1"
-	sparkConfig "github.com/hunoz/spark/config"
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
 	"github.com/pkg/errors"
 )
 
 func FetchCredentials(accountId string, roleName string, duration int32) (*types.Credentials, error) {
-	var configuration *sparkConfig.CognitoConfig
-	sparkConfig.CheckIfCognitoIsInitialized()
-	if config, e := sparkConfig.GetCognitoConfig(); e != nil {
-		if strings.Contains(e.Error(), "Invalid region") {
-			color.Red("Spark has not been initialized. Please run 'spark init' to initialize Spark.")
-		} else {
-			color.Red("Error getting config: %v", e.Error())
-		}
-		os.Exit(1)
-	} else {
-		configuration = config
+	apiContext, err := global.GetContextFromAllOptions("")
+	if err != nil {
+		color.Red(err.Error())
+		return nil, err
+	}
+
+	maroonClient, err := global.NewClientFromAllOptions(apiContext)
+	if err != nil {
+		color.Red(err.Error())
+		return nil, err
 	}
 
-	apiResponse, err := getCredentials(configuration.IdToken, v1.AssumeRoleInput{
+	credentials, err := fetchCredentials(maroonClient, accountId, roleName, duration)
+	if err != nil {
+		color.Red("Error fetching credentials: %s", err.Error())
+		return nil, errors.Wrap(err, "Error fetching credentials")
+	}
+	return credentials, nil
+}
+
+// fetchCredentials assumes the given role through the Maroon API
+func fetchCredentials(maroonClient *client.Client, accountId string, roleName string, duration int32) (*types.Credentials, error) {
+	output, err := maroonClient.AssumeRole(context.Background(), v1.AssumeRoleInput{
 		RoleArn:         fmt.Sprintf("arn:aws:iam::%s:role/%s", accountId, roleName),
 		SessionDuration: duration,
 	})
 	if err != nil {
-		color.Red("Error fetching credentials: %s", err.Error())
-		return nil, errors.Wrap(err, "Error fetching credentials")
+		return nil, err
 	}
+
 	return &types.Credentials{
-		AccessKeyId:     &apiResponse.Data.AccessKeyId,
-		SecretAccessKey: &apiResponse.Data.SecretAccessKey,
-		SessionToken:    &apiResponse.Data.SessionToken,
-		Expiration:      &apiResponse.Data.Expiration,
+		AccessKeyId:     &output.AccessKeyId,
+		SecretAccessKey: &output.SecretAccessKey,
+		SessionToken:    &output.SessionToken,
+		Expiration:      &output.Expiration,
 	}, nil
 }
 
-func getCredentials(token string, apiInput v1.AssumeRoleInput) (*v1.JSONResponse[v1.AssumeRoleOutput], error) {
-	var output v1.JSONResponse[v1.AssumeRoleOutput]
+// sessionDurationSteps are tried in turn when a role does not allow the requested session duration and the Maroon API
+// does not report its maximum. Every role allows sessions of at least an hour.
+var sessionDurationSteps = []time.Duration{8 * time.Hour, 4 * time.Hour, 2 * time.Hour, time.Hour}
 
-	req, _ := http.NewRequest(
-		"GET",
-		fmt.Sprintf("https://api.maroon.gtech.dev/api/v1/assume-role?roleArn=%s&sessionDuration=%v",
-			apiInput.RoleArn,
-			apiInput.SessionDuration,
-		),
-		nil,
-	)
-	req.Header.Add("Authorization", token)
+// negotiateCredentials fetches credentials for a profile with the longest session its role allows, up to duration.
+// It also returns the session duration that was granted.
+func negotiateCredentials(maroonClient *client.Client, profile *config.Profile, duration time.Duration) (*types.Credentials, time.Duration, error) {
+	credentials, err := fetchCredentials(maroonClient, profile.AccountId, profile.RoleToAssume, int32(duration.Seconds()))
+	for err != nil {
+		reported, ok := client.IsSessionDurationExceeded(err)
+		if !ok {
+			return nil, duration, err
+		}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error assuming role")
-	}
+		next := nextSessionDuration(duration, reported)
+		if next == 0 {
+			return nil, duration, err
+		}
 
-	if resp.StatusCode == 401 {
-		return nil, errors.New("Invalid/expired token")
-	} else if resp.StatusCode != 200 {
-		return nil, errors.New("Unable to assume role")
+		global.Verbose("Role '%s' does not allow sessions of %v, retrying with %v", profile.RoleToAssume, duration, next)
+		duration = next
+		credentials, err = fetchCredentials(maroonClient, profile.AccountId, profile.RoleToAssume, int32(duration.Seconds()))
 	}
 
-	defer resp.Body.Close()
+	return credentials, duration, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error reading response from Maroon API")
+// nextSessionDuration returns the session duration to try after duration was rejected, preferring the maximum
+// reported by the Maroon API. It returns 0 when there is nothing shorter left to try.
+func nextSessionDuration(duration time.Duration, reported time.Duration) time.Duration {
+	if reported >= config.MinSessionDuration && reported < duration {
+		return reported
 	}
 
-	err = json.Unmarshal(body, &output)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error unmarshalling Maroon API resonse")
+	for _, step := range sessionDurationSteps {
+		if step < duration {
+			return step
+		}
 	}
 
-	return &output, nil
+	return 0
 }
@@ -0,0 +1,98 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	v1 "github.com/hunoz/maroon-api/api/v1"
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/config"
+)
+
+func TestNegotiateCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxDuration   time.Duration
+		message       func(maxDuration time.Duration) string
+		wantRequested []int
+		wantGranted   time.Duration
+		wantError     bool
+	}{
+		{
+			name:          "allowed",
+			maxDuration:   12 * time.Hour,
+			wantRequested: []int{43200},
+			wantGranted:   12 * time.Hour,
+		},
+		{
+			name:        "steps down on the sts message",
+			maxDuration: 2 * time.Hour,
+			message: func(time.Duration) string {
+				return "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."
+			},
+			wantRequested: []int{43200, 28800, 14400, 7200},
+			wantGranted:   2 * time.Hour,
+		},
+		{
+			name:        "uses the reported maximum",
+			maxDuration: 3 * time.Hour,
+			message: func(maxDuration time.Duration) string {
+				return fmt.Sprintf("Unable to assume role, MaxSessionDuration: %d", int(maxDuration.Seconds()))
+			},
+			wantRequested: []int{43200, 10800},
+			wantGranted:   3 * time.Hour,
+		},
+		{
+			name:          "does not retry other errors",
+			maxDuration:   time.Hour,
+			message:       func(time.Duration) string { return "Unable to assume role" },
+			wantRequested: []int{43200},
+			wantError:     true,
+		},
+		{
+			name:        "stops after an hour",
+			maxDuration: 30 * time.Minute,
+			message: func(time.Duration) string {
+				return "The requested DurationSeconds exceeds the MaxSessionDuration set for this role."
+			},
+			wantRequested: []int{43200, 28800, 14400, 7200, 3600},
+			wantError:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requested []int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				duration, _ := strconv.Atoi(r.URL.Query().Get("sessionDuration"))
+				requested = append(requested, duration)
+
+				if time.Duration(duration)*time.Second > test.maxDuration {
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(v1.JSONError{Error: v1.Error{Message: test.message(test.maxDuration)}})
+					return
+				}
+				json.NewEncoder(w).Encode(v1.JSONResponse[v1.AssumeRoleOutput]{Data: v1.AssumeRoleOutput{AccessKeyId: "ASIAEXAMPLE"}})
+			}))
+			defer server.Close()
+
+			profile := &config.Profile{AccountId: "123456789012", RoleToAssume: "r"}
+			credentials, granted, err := negotiateCredentials(client.New(server.URL, "token", time.Second), profile, 12*time.Hour)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got error %v, want error %v", err, test.wantError)
+			}
+			if !reflect.DeepEqual(requested, test.wantRequested) {
+				t.Fatalf("requested %v, want %v", requested, test.wantRequested)
+			}
+			if !test.wantError && (granted != test.wantGranted || *credentials.AccessKeyId != "ASIAEXAMPLE") {
+				t.Fatalf("granted %v, want %v", granted, test.wantGranted)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hunoz/maroon/config"
+)
+
+// testHome is the home folder of every test in the package. The home folder is cached on first use, so it cannot
+// change between tests.
+var testHome string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "maroon-credentials-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	testHome = dir
+	os.Setenv("HOME", dir)
+	os.Setenv("USERPROFILE", dir)
+	// Settings of the user running the tests must not leak into them
+	for _, variable := range os.Environ() {
+		if name, _, _ := strings.Cut(variable, "="); strings.HasPrefix(name, "MAROON_") {
+			os.Unsetenv(name)
+		}
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeTestConfig writes configuration as the Maroon config
+func writeTestConfig(t *testing.T, configuration string) {
+	t.Helper()
+
+	configPath, err := config.GetMaroonConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(configPath, []byte(configuration), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,14 +5,15 @@ import (
 	"fmt"
 	"os"
 	"regexp"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
 	"github.com/fatih/color"
-	v1 "github.com/hunoz/maroon-api/api/v1"
+	"github.com/hunoz/maroon/agent"
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/cmd/global"
 	"github.com/hunoz/maroon/config"
-	sparkConfig "github.com/hunoz/spark/config"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -22,82 +23,190 @@ type CredentialsProcessOutput struct {
 	types.Credentials
 }
 
+// GetActiveCredentials returns credentials for a profile that are valid for longer than its refresh window, asking a running
+// Maroon agent first and refreshing them through the Maroon API otherwise. Errors exit.
 func GetActiveCredentials(profileName string) types.Credentials {
-	var sparkConfiguration *sparkConfig.CognitoConfig
-	sparkConfig.CheckIfCognitoIsInitialized()
-	if config, e := sparkConfig.GetCognitoConfig(); e != nil {
-		if strings.Contains(e.Error(), "Invalid region") {
-			color.Red("Spark has not been initialized. Please run 'spark init' to initialize Spark.")
-		} else {
-			color.Red("Error getting config: %v", e.Error())
-		}
+	credentials, err := ActiveCredentials(profileName)
+	if err != nil {
+		color.Red(err.Error())
 		os.Exit(1)
-	} else {
-		sparkConfiguration = config
 	}
 
+	return credentials
+}
+
+// ActiveCredentials is GetActiveCredentials for long-running commands, which report errors instead of exiting
+func ActiveCredentials(profileName string) (types.Credentials, error) {
 	profile, err := config.GetProfile(profileName)
 	if err != nil {
-		color.Red(err.Error())
-		os.Exit(1)
+		return types.Credentials{}, err
+	}
+
+	options, err := GetSessionOptions(profile)
+	if err != nil {
+		return types.Credentials{}, errors.Wrap(err, fmt.Sprintf("Profile '%s'", profileName))
+	}
+
+	if credentials, ok := getAgentCredentials(profileName, options.RefreshBefore); ok {
+		return credentials, nil
+	}
+
+	return ResolveCredentials(profileName, options.RefreshBefore)
+}
+
+// getAgentCredentials returns the credentials a running Maroon agent holds for a profile when they are valid for
+// longer than refreshBefore. Any problem reaching the agent falls back to resolving credentials directly.
+func getAgentCredentials(profileName string, refreshBefore time.Duration) (types.Credentials, bool) {
+	if viper.GetBool(global.FlagKey.Offline) {
+		return types.Credentials{}, false
+	}
+
+	socketPath, err := agent.GetSocketPath()
+	if err != nil {
+		return types.Credentials{}, false
+	}
+
+	credentials, err := agent.NewClient(socketPath).Credentials(profileName)
+	if errors.Is(err, agent.ErrNotRunning) {
+		return credentials, false
+	} else if err != nil {
+		global.Verbose("Not using Maroon agent for profile '%s': %v", profileName, err.Error())
+		return credentials, false
+	}
+
+	if credentials.Expiration == nil || time.Until(*credentials.Expiration) <= refreshBefore {
+		global.Verbose("Maroon agent credentials for profile '%s' expire too soon, refreshing them directly", profileName)
+		return credentials, false
+	}
+
+	global.Verbose("Using credentials for profile '%s' from the Maroon agent", profileName)
+	return credentials, true
+}
+
+// ResolveCredentials returns the cached credentials for a profile, refreshing them through the Maroon API when they
+// expire within refreshBefore
+func ResolveCredentials(profileName string, refreshBefore time.Duration) (types.Credentials, error) {
+	profile, err := config.GetProfile(profileName)
+	if err != nil {
+		return types.Credentials{}, err
 	}
 
-	var credentials types.Credentials
-	var credentialsInFileError bool
 	now := time.Now().UTC()
-	// If credentials are empty, re-fetch them
-	if profile.Credentials == (types.Credentials{}) {
-		output, err := getCredentials(sparkConfiguration.IdToken, v1.AssumeRoleInput{
-			RoleArn:         fmt.Sprintf("arn:aws:iam::%s:role/%s", profile.AccountId, profile.RoleToAssume),
-			SessionDuration: 3600,
-		})
-		if err != nil {
-			color.Red("Error fetching credentials: %s", err.Error())
-			os.Exit(1)
-		}
+	cached := profile.Credentials
+	cachedIsValid := cached != (types.Credentials{}) && cached.Expiration != nil && cached.Expiration.After(now)
 
-		credentials = types.Credentials{
-			AccessKeyId:     &output.Data.AccessKeyId,
-			SecretAccessKey: &output.Data.SecretAccessKey,
-			SessionToken:    &output.Data.SessionToken,
-			Expiration:      &output.Data.Expiration,
-		}
-		credentialsInFileError = true
-		// If the credentials expire in less than 15 minutes, refresh
-	} else if profile.Credentials.Expiration.Sub(now).Seconds() <= 900 {
-		output, err := getCredentials(sparkConfiguration.IdToken, v1.AssumeRoleInput{
-			RoleArn:         fmt.Sprintf("arn:aws:iam::%s:role/%s", profile.AccountId, profile.RoleToAssume),
-			SessionDuration: 3600,
-		})
-		if err != nil {
-			color.Red("Error fetching credentials: %s", err.Error())
-			os.Exit(1)
+	// Offline mode never touches the network and only serves credentials that have not expired yet
+	if viper.GetBool(global.FlagKey.Offline) {
+		if !cachedIsValid {
+			return cached, errors.New(fmt.Sprintf("No unexpired cached credentials for profile '%s' to use while offline", profileName))
 		}
+		global.Verbose("Offline, using cached credentials for profile '%s' that expire in %v", profileName, cached.Expiration.Sub(now).Round(time.Second))
+		return cached, nil
+	}
+
+	// If credentials are empty or expire within refreshBefore, re-fetch them
+	if cachedIsValid && cached.Expiration.Sub(now) > refreshBefore {
+		return cached, nil
+	}
+
+	// Only one process refreshes a profile at a time. Processes that waited for the lock pick up the credentials
+	// fetched by the process that held it.
+	lock, err := config.LockProfile(profileName)
+	if err != nil {
+		return cached, errors.Wrap(err, "Error locking credential cache")
+	}
+	defer lock.Unlock()
 
-		credentials = types.Credentials{
-			AccessKeyId:     &output.Data.AccessKeyId,
-			SecretAccessKey: &output.Data.SecretAccessKey,
-			SessionToken:    &output.Data.SessionToken,
-			Expiration:      &output.Data.Expiration,
+	if cached, err = config.ReadCachedCredentials(profileName); err != nil {
+		return cached, errors.Wrap(err, "Error reading credential cache")
+	}
+	now = time.Now().UTC()
+	cachedIsValid = cached != (types.Credentials{}) && cached.Expiration != nil && cached.Expiration.After(now)
+	if cachedIsValid && cached.Expiration.Sub(now) > refreshBefore {
+		global.Verbose("Using credentials for profile '%s' refreshed by another process", profileName)
+		return cached, nil
+	}
+
+	credentials, err := refreshCredentials(profileName, profile)
+	if err != nil {
+		// Keep serving cached credentials that are still valid when the API cannot be reached or the token for it has
+		// expired and could not be refreshed. Configuration mistakes are still reported.
+		if cachedIsValid && (client.IsUnavailable(err) || global.IsTokenExpired(err)) {
+			fmt.Fprintln(os.Stderr, color.YellowString("Could not refresh credentials for profile '%s': %s. Using cached credentials that expire in %v", profileName, err.Error(), cached.Expiration.Sub(now).Round(time.Second)))
+			return cached, nil
 		}
-		credentialsInFileError = true
-	} else {
-		credentials = profile.Credentials
-		credentialsInFileError = false
+
+		return cached, errors.Wrap(err, "Error fetching credentials")
 	}
 
-	if credentialsInFileError {
-		config.UpdateCredentials(profileName, credentials)
+	if err = config.UpdateCredentials(profileName, *credentials); err != nil {
+		global.Verbose("Could not cache credentials for profile '%s': %v", profileName, err.Error())
 	}
 
-	return credentials
+	return *credentials, nil
+}
+
+// refreshCredentials fetches new credentials for a profile from the Maroon API of its context. When its role does not
+// allow the requested session duration, the longest one it allows is used and remembered for the profile. A remembered
+// limit is forgotten once a longer session is granted.
+func refreshCredentials(profileName string, profile *config.Profile) (*types.Credentials, error) {
+	options, err := GetSessionOptions(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := options.Duration
+	if profile.MaxSessionDuration != "" && options.Explicit {
+		global.Verbose("Role of profile '%s' allowed sessions of at most %s before, requesting %v as asked", profileName, profile.MaxSessionDuration, duration)
+	} else if profile.MaxSessionDuration != "" {
+		global.Verbose("Role of profile '%s' allows sessions of at most %s, requesting %v", profileName, profile.MaxSessionDuration, duration)
+	}
+
+	apiContext, err := global.GetContextFromAllOptions(profile.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	maroonClient, err := global.NewClientFromAllOptions(apiContext)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, granted, err := negotiateCredentials(maroonClient, profile, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	if granted < duration {
+		fmt.Fprintln(os.Stderr, color.YellowString("Role of profile '%s' does not allow sessions of %v, using %v for this and later requests", profileName, duration, granted))
+		if err = config.SetMaxSessionDuration(profileName, granted); err != nil {
+			global.Verbose("Could not remember the session duration allowed for profile '%s': %v", profileName, err.Error())
+		}
+	} else if maxDuration, err := config.ParseDuration(profile.MaxSessionDuration); profile.MaxSessionDuration != "" && err == nil && granted > maxDuration {
+		// The role's limit was raised since it was learned, so it is forgotten and probed again when needed
+		global.Verbose("Role of profile '%s' now allows sessions of %v, forgetting the limit of %s", profileName, granted, profile.MaxSessionDuration)
+		if err = config.SetMaxSessionDuration(profileName, 0); err != nil {
+			global.Verbose("Could not forget the session duration allowed for profile '%s': %v", profileName, err.Error())
+		}
+	}
+
+	return credentials, nil
 }
 
 var PrintCredentialsCmd = &cobra.Command{
 	Use:   "print",
 	Short: "Print credentials in a format AWS SDK can understand",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// MAROON_OUTPUT and MAROON_FORMAT must not change what an AWS credential_process prints
+		viper.Set(global.FlagKey.Output, "")
+		viper.Set(global.FlagKey.Format, "")
+		cmd.Root().PersistentPreRun(cmd, args)
+	},
 	PreRun: func(cmd *cobra.Command, args []string) {
 		viper.BindPFlag(PrintFlagKey.ProfileName, cmd.Flags().Lookup(PrintFlagKey.ProfileName))
+		BindSessionFlags(cmd)
+		// Print runs as an AWS credential_process, where nobody can answer a prompt
+		viper.Set(global.FlagKey.NoInput, true)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		profileName := viper.GetString(PrintFlagKey.ProfileName)
@@ -106,21 +215,28 @@ var PrintCredentialsCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		// An AWS credential_process must print a JSON object, so MAROON_OUTPUT is ignored and other formats are refused
+		outputFormat, _ := cmd.Flags().GetString(global.FlagKey.Output)
+		if cmd.Flags().Changed(global.FlagKey.Format) || cmd.Flags().Changed(global.FlagKey.Output) && outputFormat != global.OutputJSON && outputFormat != global.OutputText {
+			color.Red("'credentials print' always prints the JSON an AWS credential_process expects. Use 'credentials export' or 'whoami' for other formats")
+			os.Exit(1)
+		}
+
 		credentials := GetActiveCredentials(profileName)
-		output := CredentialsProcessOutput{
+		marshalledOutput, err := json.Marshal(CredentialsProcessOutput{
 			Version:     1,
 			Credentials: credentials,
-		}
-		marshalledOutput, err := json.Marshal(output)
+		})
 		if err != nil {
-			color.Red("Error reading credentials from config: %s", err.Error())
+			color.Red("Failed to marshal credentials: %s", err.Error())
 			os.Exit(1)
 		}
-		color.Green(string(marshalledOutput))
+		fmt.Println(string(marshalledOutput))
 	},
 }
 
 func init() {
 	PrintCredentialsCmd.Flags().StringP(PrintFlagKey.ProfileName, "p", "", "Profile name")
 	PrintCredentialsCmd.MarkFlagRequired(PrintFlagKey.ProfileName)
+	AddSessionFlags(PrintCredentialsCmd)
 }
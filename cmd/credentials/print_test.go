@@ -0,0 +1,69 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/viper"
+)
+
+func TestResolveCredentialsFallback(t *testing.T) {
+	// A token that expired in 1970
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test","exp":1000}`))
+	tokenPath := filepath.Join(testHome, "token")
+	if err := os.WriteFile(tokenPath, []byte("e30."+claims+".sig"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		flags        map[string]interface{}
+		wantFallback bool
+	}{
+		{"expired token is downgraded", map[string]interface{}{global.FlagKey.TokenFile: tokenPath}, true},
+		{"failed token refresh is downgraded", map[string]interface{}{global.FlagKey.TokenFile: tokenPath, global.FlagKey.TokenRefresh: "exit 3"}, true},
+		{"more than one token source is not downgraded", map[string]interface{}{global.FlagKey.Token: "token", global.FlagKey.TokenFile: tokenPath}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writeTestConfig(t, `{"Endpoint":"http://127.0.0.1:1","Profiles":{"dev":{"accountId":"123456789012"}}}`)
+			// Valid, but within the refresh window
+			cached := types.Credentials{
+				AccessKeyId:     aws.String("ASIACACHED"),
+				SecretAccessKey: aws.String("secret"),
+				SessionToken:    aws.String("token"),
+				Expiration:      aws.Time(time.Now().Add(5 * time.Minute).UTC()),
+			}
+			if err := config.UpdateCredentials("dev", cached); err != nil {
+				t.Fatal(err)
+			}
+
+			t.Cleanup(viper.Reset)
+			viper.Set(global.FlagKey.NoInput, true)
+			for key, value := range test.flags {
+				viper.Set(key, value)
+			}
+
+			credentials, err := ResolveCredentials("dev", 15*time.Minute)
+			if test.wantFallback {
+				if err != nil {
+					t.Fatalf("ResolveCredentials() error = %v, want the cached credentials", err)
+				}
+				if aws.ToString(credentials.AccessKeyId) != "ASIACACHED" {
+					t.Errorf("AccessKeyId = %q, want the cached credentials", aws.ToString(credentials.AccessKeyId))
+				}
+			} else if err == nil || !strings.Contains(err.Error(), "Only one token source") {
+				t.Errorf("ResolveCredentials() error = %v, want the configuration error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var RevokeCredentialsCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke a profile's sessions through the Maroon API and clear its local credentials",
+	Long: "Revoke the session of the credentials cached for a profile through the Maroon API, so leaked credentials stop " +
+		"working before they expire. With '--all-sessions', every session of the profile's role issued before now is " +
+		"denied instead. Production profiles ask for confirmation unless '--yes' is given. Once revoked, the cached " +
+		"credentials and the sections Maroon wrote for the profile to the AWS credentials file are removed.",
+	Example: "  maroon credentials revoke -p dev\n" +
+		"  maroon credentials revoke -p prod --all-sessions",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(RevokeFlagKey.ProfileName, cmd.Flags().Lookup(RevokeFlagKey.ProfileName))
+		viper.BindPFlag(RevokeFlagKey.AllSessions, cmd.Flags().Lookup(RevokeFlagKey.AllSessions))
+		viper.BindPFlag(RevokeFlagKey.Yes, cmd.Flags().Lookup(RevokeFlagKey.Yes))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profileName := viper.GetString(RevokeFlagKey.ProfileName)
+		if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
+			color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
+			os.Exit(1)
+		}
+
+		profile, err := config.GetProfile(profileName)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		input := client.RevokeSessionsInput{
+			RoleArn:      fmt.Sprintf("arn:aws:iam::%s:role/%s", profile.AccountId, profile.RoleToAssume),
+			IssuedBefore: time.Now(),
+		}
+		description := fmt.Sprintf("every session of role '%s' issued before now", input.RoleArn)
+		if !viper.GetBool(RevokeFlagKey.AllSessions) {
+			if profile.Credentials.AccessKeyId == nil || *profile.Credentials.AccessKeyId == "" {
+				color.Red("There are no cached credentials for profile '%s' to revoke. Pass '--%s' to revoke every session of its role", profileName, RevokeFlagKey.AllSessions)
+				os.Exit(1)
+			}
+			input.AccessKeyId = *profile.Credentials.AccessKeyId
+			description = fmt.Sprintf("the session of access key '%s' for role '%s'", maskAccessKeyId(profile.Credentials.AccessKeyId), input.RoleArn)
+		}
+
+		if profile.Production && !viper.GetBool(RevokeFlagKey.Yes) {
+			if !global.IsInteractive() {
+				color.Red("Profile '%s' is a production profile. Pass '--%s' to revoke its sessions without confirmation", profileName, RevokeFlagKey.Yes)
+				os.Exit(1)
+			}
+			if !global.Confirm(profileName, "Profile '%s' is a production profile. This revokes %s", profileName, description) {
+				color.Red("Not revoking sessions for profile '%s'", profileName)
+				os.Exit(1)
+			}
+		}
+
+		apiContext, err := global.GetContextFromAllOptions(profile.Context)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		maroonClient, err := global.NewClientFromAllOptions(apiContext)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if _, err = maroonClient.RevokeSessions(context.Background(), input); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+		color.Green("Revoked %s", description)
+
+		cleared := []clearedCredentials{}
+		if credentials, removed, err := config.ClearCachedCredentials(profileName, false); err != nil {
+			color.Red("Error clearing cached credentials for profile '%s': %s", profileName, err.Error())
+			os.Exit(1)
+		} else if removed {
+			cleared = append(cleared, clearedCredentials{ProfileName: profileName, Location: clearedFromCache, AccessKeyId: maskAccessKeyId(credentials.AccessKeyId)})
+			color.Green("Removed cached credentials for profile '%s'", profileName)
+		}
+
+		sections, err := config.RemoveAwsCredentialsSections(func(section config.AwsCredentialsSection) bool {
+			return section.ProfileName == profileName
+		})
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+		for _, section := range sections {
+			cleared = append(cleared, clearedCredentials{ProfileName: profileName, Location: clearedFromAwsCredentials, Section: section.Name})
+			color.Green("Removed section '%s' of the AWS credentials file", section.Name)
+		}
+
+		warnAgentHolds(cleared)
+	},
+}
+
+func init() {
+	RevokeCredentialsCmd.Flags().StringP(RevokeFlagKey.ProfileName, "p", "", "Profile name")
+	RevokeCredentialsCmd.MarkFlagRequired(RevokeFlagKey.ProfileName)
+	RevokeCredentialsCmd.Flags().Bool(RevokeFlagKey.AllSessions, false, "Revoke every session of the profile's role issued before now instead of only the cached one")
+	RevokeCredentialsCmd.Flags().BoolP(RevokeFlagKey.Yes, "y", false, "Do not ask for confirmation for production profiles")
+}
@@ -10,5 +10,5 @@ var CredentialsCmd = &cobra.Command{
 }
 
 func init() {
-	CredentialsCmd.AddCommand(PrintCredentialsCmd, UpdateCredentialsCmd)
+	CredentialsCmd.AddCommand(PrintCredentialsCmd, UpdateCredentialsCmd, ExportCredentialsCmd, ClearCredentialsCmd, RevokeCredentialsCmd, StatusCredentialsCmd)
 }
@@ -0,0 +1,91 @@
+package credentials
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// SessionOptions are the session duration requested for a profile and how long before expiry its credentials are
+// refreshed
+type SessionOptions struct {
+	Duration      time.Duration
+	RefreshBefore time.Duration
+	// Explicit is set when the duration was passed with '--duration' or its environment variable, which is requested
+	// even when the role allowed less before
+	Explicit bool
+}
+
+// AddSessionFlags adds '--duration' and '--refresh-before' to a command that fetches credentials
+func AddSessionFlags(cmd *cobra.Command) {
+	cmd.Flags().String(SessionFlagKey.Duration, "", fmt.Sprintf("Session duration to request when fetching credentials, i.e. 2h or 3600. Overrides the profile and the Maroon config. May also be set with %s", global.EnvKey.Duration))
+	cmd.Flags().String(SessionFlagKey.RefreshBefore, "", fmt.Sprintf("Refresh cached credentials that expire within this duration, i.e. 30m. Overrides the profile and the Maroon config. May also be set with %s", global.EnvKey.RefreshBefore))
+}
+
+// BindSessionFlags binds the flags added by AddSessionFlags. It must be called from PreRun.
+func BindSessionFlags(cmd *cobra.Command) {
+	viper.BindPFlag(global.FlagKey.Duration, cmd.Flags().Lookup(SessionFlagKey.Duration))
+	viper.BindPFlag(global.FlagKey.RefreshBefore, cmd.Flags().Lookup(SessionFlagKey.RefreshBefore))
+}
+
+// GetSessionOptions returns the session options for a profile. Flags and environment variables take precedence over
+// the profile, which takes precedence over the Session config and the defaults.
+func GetSessionOptions(profile *config.Profile) (SessionOptions, error) {
+	options := SessionOptions{
+		Duration:      config.DefaultSessionDuration,
+		RefreshBefore: config.DefaultRefreshBefore,
+	}
+
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return options, err
+	}
+
+	session := config.SessionConfig{}
+	if configuration.Session != nil {
+		session = *configuration.Session
+	}
+
+	options.Explicit = viper.GetString(global.FlagKey.Duration) != ""
+	for _, value := range []string{session.SessionDuration, profile.SessionDuration, viper.GetString(global.FlagKey.Duration)} {
+		if value == "" {
+			continue
+		}
+		if options.Duration, err = config.ParseDuration(value); err != nil {
+			return options, err
+		}
+	}
+	for _, value := range []string{session.RefreshBefore, profile.RefreshBefore, viper.GetString(global.FlagKey.RefreshBefore)} {
+		if value == "" {
+			continue
+		}
+		if options.RefreshBefore, err = config.ParseDuration(value); err != nil {
+			return options, err
+		}
+	}
+
+	if err = config.ValidateSessionDuration(options.Duration); err != nil {
+		return options, err
+	} else if err = config.ValidateRefreshBefore(options.RefreshBefore, options.Duration); err != nil {
+		return options, err
+	}
+
+	// A role that allows shorter sessions than requested is asked for the longest one it allows, see
+	// negotiateCredentials. A refresh window that does not fit that session is cut to a quarter of it, as with the
+	// defaults, so credentials are not refreshed as soon as they are fetched. An explicit duration is requested as is,
+	// so a role whose limit was raised is probed again.
+	if profile.MaxSessionDuration != "" && !options.Explicit {
+		if maxDuration, err := config.ParseDuration(profile.MaxSessionDuration); err == nil && maxDuration < options.Duration {
+			options.Duration = maxDuration
+			if options.RefreshBefore >= options.Duration {
+				options.RefreshBefore = options.Duration / 4
+			}
+		}
+	}
+
+	return options, nil
+}
@@ -0,0 +1,54 @@
+package credentials
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/viper"
+)
+
+func TestGetSessionOptions(t *testing.T) {
+	tests := []struct {
+		name              string
+		session           string
+		profile           config.Profile
+		flagDuration      string
+		wantDuration      time.Duration
+		wantRefreshBefore time.Duration
+		wantError         bool
+	}{
+		{"defaults", ``, config.Profile{}, "", time.Hour, 15 * time.Minute, false},
+		{"session config", `{"sessionDuration":"2h","refreshBefore":"20m"}`, config.Profile{}, "", 2 * time.Hour, 20 * time.Minute, false},
+		{"profile overrides session config", `{"sessionDuration":"2h"}`, config.Profile{SessionDuration: "3600"}, "", time.Hour, 15 * time.Minute, false},
+		{"flag overrides profile", ``, config.Profile{SessionDuration: "2h"}, "45m", 45 * time.Minute, 15 * time.Minute, false},
+		{"too long", ``, config.Profile{SessionDuration: "13h"}, "", 0, 0, true},
+		{"refresh window longer than session", ``, config.Profile{SessionDuration: "1h", RefreshBefore: "1h"}, "", 0, 0, true},
+		{"negotiated maximum", ``, config.Profile{SessionDuration: "12h", RefreshBefore: "10m", MaxSessionDuration: "1h0m0s"}, "", time.Hour, 10 * time.Minute, false},
+		{"refresh window cut to negotiated session", ``, config.Profile{SessionDuration: "12h", RefreshBefore: "2h", MaxSessionDuration: "1h0m0s"}, "", time.Hour, 15 * time.Minute, false},
+		{"negotiated maximum above request", ``, config.Profile{SessionDuration: "1h", MaxSessionDuration: "4h0m0s"}, "", time.Hour, 15 * time.Minute, false},
+		{"explicit flag is not capped by negotiated maximum", ``, config.Profile{SessionDuration: "12h", MaxSessionDuration: "1h0m0s"}, "4h", 4 * time.Hour, 15 * time.Minute, false},
+		{"explicit flag keeps its refresh window", ``, config.Profile{RefreshBefore: "2h", MaxSessionDuration: "1h0m0s"}, "8h", 8 * time.Hour, 2 * time.Hour, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.session != "" {
+				writeTestConfig(t, `{"Session":`+test.session+`}`)
+			} else {
+				writeTestConfig(t, `{}`)
+			}
+			viper.Set(global.FlagKey.Duration, test.flagDuration)
+			defer viper.Set(global.FlagKey.Duration, "")
+
+			options, err := GetSessionOptions(&test.profile)
+			if (err != nil) != test.wantError {
+				t.Fatalf("got error %v, want error %v", err, test.wantError)
+			}
+			if !test.wantError && (options.Duration != test.wantDuration || options.RefreshBefore != test.wantRefreshBefore) {
+				t.Fatalf("got %v and %v, want %v and %v", options.Duration, options.RefreshBefore, test.wantDuration, test.wantRefreshBefore)
+			}
+		})
+	}
+}
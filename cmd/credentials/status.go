@@ -0,0 +1,330 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/hunoz/maroon/token"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// credentialsStatus is what the status command reports
+type credentialsStatus struct {
+	Tokens   []tokenStatus   `json:"tokens"`
+	Profiles []profileStatus `json:"profiles"`
+}
+
+// tokenStatus describes the Maroon API token of a context, decoded locally. Context is empty for the endpoint set
+// without a context.
+type tokenStatus struct {
+	Context    string     `json:"context,omitempty"`
+	Source     string     `json:"source,omitempty"`
+	Subject    string     `json:"subject,omitempty"`
+	Username   string     `json:"username,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	Groups     []string   `json:"groups,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	TTL        string     `json:"ttl,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// profileStatus describes the cached credentials of a profile and, with '--verify', the identity STS reports for them
+type profileStatus struct {
+	ProfileName string `json:"profileName"`
+	AccountId   string `json:"accountId"`
+	Role        string `json:"role"`
+	// Context is the Maroon API context credentials of the profile are fetched from
+	Context     string     `json:"context,omitempty"`
+	AccessKeyId string     `json:"accessKeyId,omitempty"`
+	Expiration  *time.Time `json:"expiration,omitempty"`
+	TTL         string     `json:"ttl,omitempty"`
+	// SessionDuration and RefreshBefore are the options used the next time credentials are fetched
+	SessionDuration string `json:"sessionDuration"`
+	RefreshBefore   string `json:"refreshBefore"`
+	// MaxSessionDuration is the longest session the role allowed when a longer one was requested
+	MaxSessionDuration string          `json:"maxSessionDuration,omitempty"`
+	RefreshDue         bool            `json:"refreshDue"`
+	Identity           *callerIdentity `json:"identity,omitempty"`
+	VerifyError        string          `json:"verifyError,omitempty"`
+}
+
+// callerIdentity is the result of STS GetCallerIdentity
+type callerIdentity struct {
+	Account string `json:"account"`
+	Arn     string `json:"arn"`
+	UserId  string `json:"userId"`
+}
+
+var StatusCredentialsCmd = newStatusCmd("status")
+
+// WhoamiCmd is 'maroon whoami', a shortcut for 'maroon credentials status'
+var WhoamiCmd = newStatusCmd("whoami")
+
+func newStatusCmd(use string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: "Show which identity profiles resolve to and how long their credentials are valid",
+		Long: "Show the account, role, masked access key ID, expiration and remaining lifetime of the credentials cached " +
+			"for each profile, whether they are due for a refresh, and the subject, groups and expiry of the Maroon API " +
+			"token of each context the profiles belong to. Nothing is sent over the network unless '--verify' is given, which calls STS GetCallerIdentity with " +
+			"the cached credentials.",
+		Example: fmt.Sprintf("  maroon %s\n", use) +
+			fmt.Sprintf("  maroon %s -p prod --verify\n", use) +
+			fmt.Sprintf("  maroon %s -p dev --verify --sts-endpoint http://127.0.0.1:4566", use),
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlag(StatusFlagKey.ProfileName, cmd.Flags().Lookup(StatusFlagKey.ProfileName))
+			viper.BindPFlag(StatusFlagKey.Verify, cmd.Flags().Lookup(StatusFlagKey.Verify))
+			viper.BindPFlag(StatusFlagKey.StsEndpoint, cmd.Flags().Lookup(StatusFlagKey.StsEndpoint))
+			viper.BindEnv(StatusFlagKey.StsEndpoint, global.EnvKey.StsEndpoint, "AWS_ENDPOINT_URL_STS")
+		},
+		Run: runStatus,
+	}
+
+	cmd.Flags().StringSliceP(StatusFlagKey.ProfileName, "p", nil, "Profiles to show. Defaults to every profile")
+	cmd.Flags().Bool(StatusFlagKey.Verify, false, "Verify the cached credentials with STS GetCallerIdentity")
+	cmd.Flags().String(StatusFlagKey.StsEndpoint, "", fmt.Sprintf("STS endpoint to verify credentials against. May also be set with %s or AWS_ENDPOINT_URL_STS", global.EnvKey.StsEndpoint))
+
+	return cmd
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	configuration, err := config.GetConfig()
+	if err != nil {
+		color.Red(err.Error())
+		os.Exit(1)
+	}
+
+	profileNames := viper.GetStringSlice(StatusFlagKey.ProfileName)
+	if len(profileNames) == 0 {
+		for profileName := range configuration.Profiles {
+			profileNames = append(profileNames, profileName)
+		}
+		sort.Strings(profileNames)
+	}
+
+	status := credentialsStatus{Tokens: []tokenStatus{}, Profiles: []profileStatus{}}
+	// The contexts whose token is reported, in the order profiles first use them
+	var contextNames []string
+	seenContexts := map[string]bool{}
+	for _, profileName := range profileNames {
+		if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
+			color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
+			os.Exit(1)
+		}
+
+		profile, err := config.GetProfile(profileName)
+		if err != nil {
+			color.Red("Error reading profile '%s': %s", profileName, err.Error())
+			os.Exit(1)
+		}
+
+		options, err := GetSessionOptions(profile)
+		if err != nil {
+			color.Red("Profile '%s': %s", profileName, err.Error())
+			os.Exit(1)
+		}
+
+		contextName := getStatusContextName(profile.Context, configuration)
+		if !seenContexts[contextName] {
+			seenContexts[contextName] = true
+			contextNames = append(contextNames, contextName)
+		}
+
+		entry := profileStatus{
+			ProfileName:        profileName,
+			AccountId:          profile.AccountId,
+			Role:               profile.RoleToAssume,
+			Context:            contextName,
+			AccessKeyId:        maskAccessKeyId(profile.Credentials.AccessKeyId),
+			Expiration:         profile.Credentials.Expiration,
+			SessionDuration:    options.Duration.String(),
+			RefreshBefore:      options.RefreshBefore.String(),
+			MaxSessionDuration: profile.MaxSessionDuration,
+			RefreshDue:         true,
+		}
+		if expiration := profile.Credentials.Expiration; expiration != nil {
+			entry.TTL = formatTTL(*expiration)
+			entry.RefreshDue = time.Until(*expiration) <= options.RefreshBefore
+		}
+
+		if viper.GetBool(StatusFlagKey.Verify) {
+			if profile.Credentials.Expiration == nil || !profile.Credentials.Expiration.After(time.Now()) {
+				entry.VerifyError = "No unexpired cached credentials to verify"
+			} else if entry.Identity, err = verifyCredentials(profile.Credentials, profile.Region); err != nil {
+				entry.VerifyError = err.Error()
+			}
+		}
+
+		status.Profiles = append(status.Profiles, entry)
+	}
+
+	// Without profiles, the token of the context a command would use is still of interest
+	if len(contextNames) == 0 {
+		contextNames = append(contextNames, getStatusContextName("", configuration))
+	}
+	for _, contextName := range contextNames {
+		status.Tokens = append(status.Tokens, getTokenStatus(contextName))
+	}
+
+	err = global.PrintOutput(status, global.OutputText, func(w io.Writer) {
+		for _, entry := range status.Tokens {
+			label := "Token"
+			if entry.Context != "" {
+				label = fmt.Sprintf("Token of context '%s'", entry.Context)
+			}
+
+			if entry.Error != "" {
+				fmt.Fprintf(w, "%s: %s\n", label, entry.Error)
+			} else {
+				fmt.Fprintf(w, "%s from %s\n", label, entry.Source)
+				fmt.Fprintf(w, "  subject: %s\n", entry.Subject)
+				if entry.Username != "" {
+					fmt.Fprintf(w, "  username: %s\n", entry.Username)
+				}
+				if len(entry.Groups) > 0 {
+					fmt.Fprintf(w, "  groups: %s\n", strings.Join(entry.Groups, ", "))
+				}
+				if entry.Expiration != nil {
+					fmt.Fprintf(w, "  expires: %s (%s)\n", entry.Expiration.Local().Format(time.RFC1123), entry.TTL)
+				}
+			}
+		}
+
+		for _, profile := range status.Profiles {
+			fmt.Fprintf(w, "Profile %s\n", profile.ProfileName)
+			fmt.Fprintf(w, "  role: arn:aws:iam::%s:role/%s\n", profile.AccountId, profile.Role)
+			if profile.Context != "" {
+				fmt.Fprintf(w, "  context: %s\n", profile.Context)
+			}
+			if profile.Expiration == nil {
+				fmt.Fprintln(w, "  credentials: none cached")
+			} else {
+				fmt.Fprintf(w, "  access key: %s\n", profile.AccessKeyId)
+				fmt.Fprintf(w, "  expires: %s (%s)\n", profile.Expiration.Local().Format(time.RFC1123), profile.TTL)
+			}
+			if profile.RefreshDue {
+				fmt.Fprintf(w, "  refresh due: yes (session %s, refreshed %s before expiry)\n", profile.SessionDuration, profile.RefreshBefore)
+			} else {
+				fmt.Fprintf(w, "  refresh due: no (session %s, refreshed %s before expiry)\n", profile.SessionDuration, profile.RefreshBefore)
+			}
+			if profile.MaxSessionDuration != "" {
+				fmt.Fprintf(w, "  role allows sessions of at most: %s\n", profile.MaxSessionDuration)
+			}
+			if profile.Identity != nil {
+				fmt.Fprintf(w, "  verified: %s\n", profile.Identity.Arn)
+			} else if profile.VerifyError != "" {
+				fmt.Fprintf(w, "  verify failed: %s\n", profile.VerifyError)
+			}
+		}
+	})
+	if err != nil {
+		color.Red(err.Error())
+		os.Exit(1)
+	}
+}
+
+// getStatusContextName returns the name of the context credentials of a profile in profileContext are fetched from.
+// It is empty for the endpoint set without a context.
+func getStatusContextName(profileContext string, configuration *config.Config) string {
+	if contextName := viper.GetString(global.FlagKey.Context); contextName != "" {
+		return contextName
+	} else if profileContext != "" {
+		return profileContext
+	}
+	return configuration.CurrentContext
+}
+
+// getTokenStatus decodes the Maroon API token of a context without verifying or refreshing it
+func getTokenStatus(contextName string) tokenStatus {
+	apiContext, err := config.ResolveContext(contextName)
+	if err != nil {
+		return tokenStatus{Context: contextName, Error: err.Error()}
+	}
+
+	chain, err := global.GetTokenChainFromAllOptions(apiContext)
+	if err != nil {
+		return tokenStatus{Context: contextName, Error: err.Error()}
+	}
+
+	value, source, err := chain.Token()
+	if err != nil {
+		return tokenStatus{Context: contextName, Error: fmt.Sprintf("No token found in %s", strings.Join(chain.Names(), ", "))}
+	}
+
+	claims, err := token.ParseClaims(value)
+	if err != nil {
+		return tokenStatus{Context: contextName, Source: source, Error: err.Error()}
+	}
+
+	status := tokenStatus{
+		Context:  contextName,
+		Source:   source,
+		Subject:  claims.Subject,
+		Username: claims.Username,
+		Email:    claims.Email,
+		Groups:   claims.Groups,
+	}
+	if expiresAt := claims.ExpiresAt(); !expiresAt.IsZero() {
+		status.Expiration = &expiresAt
+		status.TTL = formatTTL(expiresAt)
+	}
+
+	return status
+}
+
+// verifyCredentials calls STS GetCallerIdentity with SigV4 signed requests made from credentials
+func verifyCredentials(credentials types.Credentials, region string) (*callerIdentity, error) {
+	httpClient, err := global.NewHTTPClientFromAllOptions(viper.GetDuration(global.FlagKey.Timeout))
+	if err != nil {
+		return nil, err
+	}
+
+	options := sts.Options{
+		Region:     region,
+		HTTPClient: httpClient,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{
+				AccessKeyID:     *credentials.AccessKeyId,
+				SecretAccessKey: *credentials.SecretAccessKey,
+				SessionToken:    *credentials.SessionToken,
+				Source:          "Maroon",
+			}, nil
+		}),
+	}
+	if endpoint := viper.GetString(StatusFlagKey.StsEndpoint); endpoint != "" {
+		options.EndpointResolver = sts.EndpointResolverFromURL(endpoint)
+	}
+
+	output, err := sts.New(options).GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &callerIdentity{
+		Account: aws.ToString(output.Account),
+		Arn:     aws.ToString(output.Arn),
+		UserId:  aws.ToString(output.UserId),
+	}, nil
+}
+
+// formatTTL describes how long until expiration, or that it has passed
+func formatTTL(expiration time.Time) string {
+	remaining := time.Until(expiration).Round(time.Second)
+	if remaining <= 0 {
+		return "expired"
+	}
+	return remaining.String()
+}
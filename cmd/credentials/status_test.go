@@ -0,0 +1,52 @@
+package credentials
+
+import (
+	"testing"
+
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/viper"
+)
+
+func TestGetStatusContextName(t *testing.T) {
+	configuration := &config.Config{CurrentContext: "current"}
+
+	tests := []struct {
+		name           string
+		profileContext string
+		flagContext    string
+		want           string
+	}{
+		{"current context", "", "", "current"},
+		{"profile context", "staging", "", "staging"},
+		{"flag overrides profile", "staging", "prod", "prod"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set(global.FlagKey.Context, test.flagContext)
+
+			if got := getStatusContextName(test.profileContext, configuration); got != test.want {
+				t.Errorf("getStatusContextName(%q) = %q, want %q", test.profileContext, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetTokenStatusContext(t *testing.T) {
+	writeTestConfig(t, `{"Contexts":{"staging":{"endpoint":"http://127.0.0.1:1"}},"Token":{"sources":["env"]}}`)
+	t.Setenv(global.EnvKey.Token, "not-a-jwt")
+
+	status := getTokenStatus("staging")
+	if status.Context != "staging" {
+		t.Errorf("Context = %q, want %q", status.Context, "staging")
+	}
+	if status.Source == "" {
+		t.Errorf("Source is empty, want the token source of the context")
+	}
+
+	if status = getTokenStatus("missing"); status.Context != "missing" || status.Error == "" {
+		t.Errorf("getTokenStatus(\"missing\") = %+v, want an error labelled with the context", status)
+	}
+}
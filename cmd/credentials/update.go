@@ -3,33 +3,107 @@ package credentials
 import (
 	"os"
 	"regexp"
+	"sort"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var UpdateCredentialsCmd = &cobra.Command{
 	Use:   "update",
-	Short: "Places the credentials for a profile in the AWS credentials file under default",
+	Short: "Places the credentials for a profile in the AWS credentials file",
+	Long: "Places the credentials for a profile in the AWS credentials file, under the default section or the section given " +
+		"with '--target'. With '--all', every Maroon profile is written to a section named after it. Sections holding " +
+		"long-term access keys are only overwritten with '--force'. The previous file is backed up and can be restored " +
+		"with 'maroon config restore'.",
+	Example: "  maroon credentials update -p prod\n" +
+		"  maroon credentials update -p prod --target prod-static\n" +
+		"  maroon credentials update --all",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		viper.BindPFlag(UpdateFlagKey.ProfileName, cmd.Flags().Lookup(UpdateFlagKey.ProfileName))
+		viper.BindPFlag(UpdateFlagKey.Target, cmd.Flags().Lookup(UpdateFlagKey.Target))
+		viper.BindPFlag(UpdateFlagKey.All, cmd.Flags().Lookup(UpdateFlagKey.All))
+		viper.BindPFlag(UpdateFlagKey.Force, cmd.Flags().Lookup(UpdateFlagKey.Force))
+		BindSessionFlags(cmd)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		profileName := viper.GetString(UpdateFlagKey.ProfileName)
-		if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
-			color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
-			os.Exit(1)
+		var sections []config.AwsCredentialsSection
+		if viper.GetBool(UpdateFlagKey.All) {
+			configuration, err := config.GetConfig()
+			if err != nil {
+				color.Red(err.Error())
+				os.Exit(1)
+			}
+
+			profileNames := make([]string, 0, len(configuration.Profiles))
+			for profileName := range configuration.Profiles {
+				profileNames = append(profileNames, profileName)
+			}
+			sort.Strings(profileNames)
+
+			for _, profileName := range profileNames {
+				sections = append(sections, config.AwsCredentialsSection{
+					Name:        profileName,
+					ProfileName: profileName,
+					Credentials: GetActiveCredentials(profileName),
+				})
+			}
+		} else {
+			profileName := viper.GetString(UpdateFlagKey.ProfileName)
+			if profileName == "" {
+				color.Red("Pass the profile to update with '--%s' or update every profile with '--%s'", UpdateFlagKey.ProfileName, UpdateFlagKey.All)
+				os.Exit(1)
+			} else if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
+				color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
+				os.Exit(1)
+			}
+
+			target := viper.GetString(UpdateFlagKey.Target)
+			if !regexp.MustCompile("^[0-9a-zA-Z._@+-]{1,64}$").MatchString(target) {
+				color.Red("Target '%s' is not allowed. Target must only contain alphanumeric characters and the following special characters: '.', '_', '@', '+', '-'", target)
+				os.Exit(1)
+			}
+
+			sections = append(sections, config.AwsCredentialsSection{
+				Name:        target,
+				ProfileName: profileName,
+				Credentials: GetActiveCredentials(profileName),
+			})
+		}
+
+		if len(sections) == 0 {
+			color.Cyan("There are no profiles to update")
+			return
 		}
 
-		credentials := GetActiveCredentials(profileName)
+		static, err := config.UpdateAwsCredentialsFile(sections, viper.GetBool(UpdateFlagKey.Force))
+		if errors.Cause(err) == config.ErrStaticCredentials {
+			color.Red("%s. Pass '--%s' to replace them, the previous file is backed up first", err.Error(), UpdateFlagKey.Force)
+			os.Exit(1)
+		} else if err != nil {
+			color.Red("Error updating AWS credentials file: %s", err.Error())
+			os.Exit(1)
+		}
 
-		config.UpdateAwsCredentialsFile(credentials)
+		for _, section := range static {
+			color.Yellow("Replaced long-term access keys in section '%s'. Restore them with 'maroon config restore --file %s'", section, config.ManagedAwsCredentials)
+		}
+		for _, section := range sections {
+			color.Green("Wrote credentials for profile '%s' to section '%s', they expire at %s", section.ProfileName, section.Name, section.Credentials.Expiration.Local().Format(time.RFC1123))
+		}
 	},
 }
 
 func init() {
 	UpdateCredentialsCmd.Flags().StringP(UpdateFlagKey.ProfileName, "p", "", "Profile name")
-	UpdateCredentialsCmd.MarkFlagRequired(UpdateFlagKey.ProfileName)
+	UpdateCredentialsCmd.Flags().StringP(UpdateFlagKey.Target, "t", "default", "Section of the AWS credentials file to write the credentials to")
+	UpdateCredentialsCmd.Flags().Bool(UpdateFlagKey.All, false, "Write every Maroon profile to a section named after it")
+	UpdateCredentialsCmd.Flags().Bool(UpdateFlagKey.Force, false, "Overwrite sections holding long-term access keys")
+	AddSessionFlags(UpdateCredentialsCmd)
+	UpdateCredentialsCmd.MarkFlagsMutuallyExclusive(UpdateFlagKey.All, UpdateFlagKey.ProfileName)
+	UpdateCredentialsCmd.MarkFlagsMutuallyExclusive(UpdateFlagKey.All, UpdateFlagKey.Target)
 }
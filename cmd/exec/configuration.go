@@ -0,0 +1,7 @@
+package execcmd
+
+var FlagKey = struct {
+	ProfileName string
+}{
+	ProfileName: "profile-name",
+}
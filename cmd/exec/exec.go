@@ -0,0 +1,141 @@
+package execcmd
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/credentials"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// scrubbedEnv are the AWS variables that could make the command use other credentials or another region than the
+// profile's, in addition to the ones Maroon sets
+var scrubbedEnv = append([]string{
+	"AWS_SECURITY_TOKEN",
+	"AWS_ACCESS_KEY",
+	"AWS_SECRET_KEY",
+	"AWS_SHARED_CREDENTIALS_FILE",
+	"AWS_CONFIG_FILE",
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_ROLE_ARN",
+	"AWS_ROLE_SESSION_NAME",
+	"AWS_WEB_IDENTITY_TOKEN_FILE",
+	"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN",
+	"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+}, credentials.CredentialsEnvVarNames...)
+
+var ExecCmd = &cobra.Command{
+	Use:   "exec -p <profile-name> [--] <command> [args...]",
+	Short: "Run a command with a profile's credentials in its environment",
+	Long: "Run a command with a profile's credentials and region in its environment, without touching the AWS config or " +
+		"credentials files. AWS variables that would conflict are removed first. Signals are passed to the command and Maroon " +
+		"exits with its exit code. Flags after the command are passed to it, so '--' is optional.",
+	Args: cobra.MinimumNArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(FlagKey.ProfileName, cmd.Flags().Lookup(FlagKey.ProfileName))
+		credentials.BindSessionFlags(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profileName := viper.GetString(FlagKey.ProfileName)
+		if !regexp.MustCompile("^[0-9a-zA-Z-]{1,64}$").MatchString(profileName) {
+			color.Red("Profile name '%s' is not allowed. Profile name must only contain alphanumeric characters and the following special characters: '-'", profileName)
+			os.Exit(1)
+		}
+
+		profile, err := config.GetProfile(profileName)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		activeCredentials := credentials.GetActiveCredentials(profileName)
+
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			color.Red("Cannot run '%s': %v", args[0], err.Error())
+			os.Exit(1)
+		}
+
+		child := exec.Command(path, args[1:]...)
+		child.Env = credentialsEnv(os.Environ(), activeCredentials, profile.Region)
+		child.Stdin = os.Stdin
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+
+		// Take over signals before starting the command so none are lost in between
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, forwardedSignals...)
+		defer signal.Stop(signals)
+
+		if err = child.Start(); err != nil {
+			color.Red("Cannot run '%s': %v", args[0], err.Error())
+			os.Exit(1)
+		}
+
+		go func() {
+			for sig := range signals {
+				child.Process.Signal(sig)
+			}
+		}()
+
+		child.Wait()
+		os.Exit(exitCode(child.ProcessState))
+	},
+}
+
+// credentialsEnv returns environ without conflicting AWS variables and with the credentials and region added
+func credentialsEnv(environ []string, activeCredentials types.Credentials, region string) []string {
+	var env []string
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		if !isScrubbed(name) {
+			env = append(env, variable)
+		}
+	}
+
+	for _, variable := range credentials.CredentialsEnvVars(activeCredentials, region) {
+		env = append(env, variable.Name+"="+variable.Value)
+	}
+
+	return env
+}
+
+// isScrubbed reports whether an environment variable is removed before running the command. On Windows, names are
+// compared case insensitively like Windows does.
+func isScrubbed(name string) bool {
+	for _, scrubbed := range scrubbedEnv {
+		if name == scrubbed || runtime.GOOS == "windows" && strings.EqualFold(name, scrubbed) {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode returns the exit code of the command, following the shell convention of 128 plus the signal number for
+// commands killed by a signal
+func exitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return state.ExitCode()
+}
+
+func init() {
+	ExecCmd.Flags().StringP(FlagKey.ProfileName, "p", "", "Profile name")
+	credentials.AddSessionFlags(ExecCmd)
+	ExecCmd.MarkFlagRequired(FlagKey.ProfileName)
+	// Everything after the command belongs to it, i.e. 'maroon exec -p dev aws s3 ls --recursive'
+	ExecCmd.Flags().SetInterspersed(false)
+}
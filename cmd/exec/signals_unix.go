@@ -0,0 +1,19 @@
+//go:build !windows
+
+package execcmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are passed on to the command
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package execcmd
+
+import "os"
+
+// forwardedSignals are caught so Maroon keeps running until the command exits. Windows delivers Ctrl+C to every
+// process attached to the console, so the command receives it directly.
+var forwardedSignals = []os.Signal{os.Interrupt}
@@ -0,0 +1,97 @@
+package global
+
+var FlagKey = struct {
+	Context       string
+	Endpoint      string
+	Timeout       string
+	MaxAttempts   string
+	RetryDeadline string
+	Proxy         string
+	NoProxy       string
+	CABundle      string
+	ClientCert    string
+	ClientKey     string
+	Token         string
+	TokenFile     string
+	TokenStdin    string
+	TokenCommand  string
+	TokenWarning  string
+	TokenRefresh  string
+	NoInput       string
+	Offline       string
+	Verbose       string
+	Output        string
+	Format        string
+	NoColor       string
+	Duration      string
+	RefreshBefore string
+}{
+	Context:       "context",
+	Endpoint:      "endpoint",
+	Timeout:       "timeout",
+	MaxAttempts:   "max-attempts",
+	RetryDeadline: "retry-deadline",
+	Proxy:         "proxy",
+	NoProxy:       "no-proxy",
+	CABundle:      "ca-bundle",
+	ClientCert:    "client-cert",
+	ClientKey:     "client-key",
+	Token:         "token",
+	TokenFile:     "token-file",
+	TokenStdin:    "token-stdin",
+	TokenCommand:  "token-command",
+	TokenWarning:  "token-expiry-warning",
+	TokenRefresh:  "token-refresh-command",
+	NoInput:       "no-input",
+	Offline:       "offline",
+	Verbose:       "verbose",
+	Output:        "output",
+	Format:        "format",
+	NoColor:       "no-color",
+	Duration:      "session-duration",
+	RefreshBefore: "refresh-before",
+}
+
+var EnvKey = struct {
+	Context       string
+	Endpoint      string
+	Timeout       string
+	MaxAttempts   string
+	RetryDeadline string
+	Proxy         string
+	NoProxy       string
+	CABundle      string
+	ClientCert    string
+	ClientKey     string
+	Token         string
+	TokenFile     string
+	TokenWarning  string
+	TokenRefresh  string
+	Offline       string
+	Output        string
+	NoColor       string
+	StsEndpoint   string
+	Duration      string
+	RefreshBefore string
+}{
+	Context:       "MAROON_CONTEXT",
+	Endpoint:      "MAROON_ENDPOINT",
+	Timeout:       "MAROON_TIMEOUT",
+	MaxAttempts:   "MAROON_MAX_ATTEMPTS",
+	RetryDeadline: "MAROON_RETRY_DEADLINE",
+	Proxy:         "MAROON_PROXY",
+	NoProxy:       "MAROON_NO_PROXY",
+	CABundle:      "MAROON_CA_BUNDLE",
+	ClientCert:    "MAROON_CLIENT_CERT",
+	ClientKey:     "MAROON_CLIENT_KEY",
+	Token:         "MAROON_TOKEN",
+	TokenFile:     "MAROON_TOKEN_FILE",
+	TokenWarning:  "MAROON_TOKEN_EXPIRY_WARNING",
+	TokenRefresh:  "MAROON_TOKEN_REFRESH_COMMAND",
+	Offline:       "MAROON_OFFLINE",
+	Output:        "MAROON_OUTPUT",
+	NoColor:       "NO_COLOR",
+	StsEndpoint:   "MAROON_STS_ENDPOINT",
+	Duration:      "MAROON_SESSION_DURATION",
+	RefreshBefore: "MAROON_REFRESH_BEFORE",
+}
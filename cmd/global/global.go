@@ -0,0 +1,176 @@
+package global
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// Verbose prints a diagnostic message to stderr when '--verbose' is set
+func Verbose(format string, args ...interface{}) {
+	if viper.GetBool(FlagKey.Verbose) {
+		fmt.Fprintln(os.Stderr, color.HiBlackString(format, args...))
+	}
+}
+
+// Confirm asks the user to type expected to go ahead with an operation described by format. It returns false when
+// the answer does not match or when prompting is not allowed.
+func Confirm(expected string, format string, args ...interface{}) bool {
+	if !IsInteractive() {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "%s. Type '%s' to continue: ", fmt.Sprintf(format, args...), expected)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(answer) == expected
+}
+
+// GetContextFromAllOptions returns the API context to use. The '--context' flag takes precedence over
+// profileContext, which takes precedence over the current context.
+func GetContextFromAllOptions(profileContext string) (*config.ApiContext, error) {
+	contextName := viper.GetString(FlagKey.Context)
+	if contextName == "" {
+		contextName = profileContext
+	}
+
+	return config.ResolveContext(contextName)
+}
+
+// GetApiUrlFromAllOptions returns the Maroon API URL for the given context, preferring the '--endpoint' flag and
+// MAROON_ENDPOINT environment variable over the context's endpoint
+func GetApiUrlFromAllOptions(apiContext *config.ApiContext) (string, error) {
+	return config.GetApiUrl(apiContext, viper.GetString(FlagKey.Endpoint))
+}
+
+// GetRetryPolicyFromAllOptions returns the retry policy for Maroon API calls. Flags and environment variables take
+// precedence over the Maroon config, which takes precedence over client.DefaultRetryPolicy.
+func GetRetryPolicyFromAllOptions() (client.RetryPolicy, error) {
+	policy := client.DefaultRetryPolicy
+
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return policy, err
+	}
+
+	if configuration.Retry != nil {
+		if configuration.Retry.MaxAttempts > 0 {
+			policy.MaxAttempts = configuration.Retry.MaxAttempts
+		}
+		if configuration.Retry.Deadline != "" {
+			deadline, err := time.ParseDuration(configuration.Retry.Deadline)
+			if err != nil {
+				return policy, errors.Wrap(err, fmt.Sprintf("Invalid retry deadline '%s' in Maroon config", configuration.Retry.Deadline))
+			}
+			policy.Deadline = deadline
+		}
+	}
+
+	if viper.IsSet(FlagKey.MaxAttempts) {
+		policy.MaxAttempts = viper.GetInt(FlagKey.MaxAttempts)
+	}
+	if viper.IsSet(FlagKey.RetryDeadline) {
+		policy.Deadline = viper.GetDuration(FlagKey.RetryDeadline)
+	}
+
+	if policy.MaxAttempts < 1 {
+		return policy, errors.New("Max attempts must be at least 1")
+	}
+
+	return policy, nil
+}
+
+// stringFromAllOptions returns the flag or environment variable for key when set, otherwise configValue
+func stringFromAllOptions(key string, configValue string) string {
+	if viper.IsSet(key) {
+		return viper.GetString(key)
+	}
+	return configValue
+}
+
+// GetTransportOptionsFromAllOptions returns the proxy and TLS settings for outgoing requests. Flags and environment
+// variables take precedence over the Maroon config.
+func GetTransportOptionsFromAllOptions() (client.TransportOptions, error) {
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return client.TransportOptions{}, err
+	}
+
+	network := config.NetworkConfig{}
+	if configuration.Network != nil {
+		network = *configuration.Network
+	}
+
+	options := client.TransportOptions{
+		Proxy:      stringFromAllOptions(FlagKey.Proxy, network.Proxy),
+		NoProxy:    stringFromAllOptions(FlagKey.NoProxy, network.NoProxy),
+		CABundle:   stringFromAllOptions(FlagKey.CABundle, network.CABundle),
+		ClientCert: stringFromAllOptions(FlagKey.ClientCert, network.ClientCert),
+		ClientKey:  stringFromAllOptions(FlagKey.ClientKey, network.ClientKey),
+	}
+
+	for _, path := range []*string{&options.CABundle, &options.ClientCert, &options.ClientKey} {
+		if *path, err = config.ExpandPath(*path); err != nil {
+			return options, err
+		}
+	}
+
+	return options, nil
+}
+
+// NewHTTPClientFromAllOptions creates an HTTP client that uses the configured proxy and TLS settings
+func NewHTTPClientFromAllOptions(timeout time.Duration) (*http.Client, error) {
+	options, err := GetTransportOptionsFromAllOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := client.NewTransport(options)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error configuring network settings")
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// NewClientFromAllOptions creates a Maroon API client for the given context, using the token, endpoint, timeout and
+// retry settings from flags, environment variables and the Maroon config
+func NewClientFromAllOptions(apiContext *config.ApiContext) (*client.Client, error) {
+	token, refresh, err := GetTokenFromAllOptions(apiContext)
+	if err != nil {
+		return nil, err
+	}
+
+	apiUrl, err := GetApiUrlFromAllOptions(apiContext)
+	if err != nil {
+		return nil, err
+	}
+
+	maroonClient := client.New(apiUrl, token, viper.GetDuration(FlagKey.Timeout))
+	maroonClient.RefreshToken = refresh
+	maroonClient.Logf = Verbose
+
+	if maroonClient.HTTPClient, err = NewHTTPClientFromAllOptions(maroonClient.HTTPClient.Timeout); err != nil {
+		return nil, err
+	}
+	if maroonClient.Retry, err = GetRetryPolicyFromAllOptions(); err != nil {
+		return nil, err
+	}
+
+	return maroonClient, nil
+}
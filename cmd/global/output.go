@@ -0,0 +1,202 @@
+package global
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by '--output'
+const (
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputTable = "table"
+	OutputText  = "text"
+)
+
+var outputFormats = []string{OutputJSON, OutputYAML, OutputTable, OutputText}
+
+// ConfigureOutput sends colored messages to stderr and disables color when '--no-color' or NO_COLOR is set or stderr
+// is not a terminal, so stdout only ever holds the data a command prints
+func ConfigureOutput() error {
+	color.Output = color.Error
+	if viper.GetBool(FlagKey.NoColor) || os.Getenv(EnvKey.NoColor) != "" || !isTerminal(os.Stderr) {
+		color.NoColor = true
+	}
+
+	if format := viper.GetString(FlagKey.Output); format != "" {
+		for _, outputFormat := range outputFormats {
+			if format == outputFormat {
+				return nil
+			}
+		}
+		return errors.New(fmt.Sprintf("Output format '%s' is not supported. Must be one of %s", format, strings.Join(outputFormats, ", ")))
+	}
+
+	return nil
+}
+
+// PrintOutput writes data to stdout as the Go template given with '--format', in the format given with '--output' or
+// in defaultFormat. text renders data for the text format.
+func PrintOutput(data interface{}, defaultFormat string, text func(w io.Writer)) error {
+	if format := viper.GetString(FlagKey.Format); format != "" {
+		tmpl, err := template.New("format").Option("missingkey=error").Parse(format)
+		if err != nil {
+			return errors.Wrap(err, "Invalid format template")
+		}
+
+		var out bytes.Buffer
+		if err = tmpl.Execute(&out, data); err != nil {
+			return errors.Wrap(err, "Cannot render format template")
+		}
+		if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+			out.WriteString("\n")
+		}
+		_, err = os.Stdout.Write(out.Bytes())
+		return err
+	}
+
+	format := viper.GetString(FlagKey.Output)
+	if format == "" {
+		format = defaultFormat
+	}
+
+	switch format {
+	case OutputJSON:
+		marshalled, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "Cannot format output as JSON")
+		}
+		fmt.Println(string(marshalled))
+	case OutputYAML:
+		node, err := toNode(data)
+		if err != nil {
+			return err
+		}
+		marshalled, err := yaml.Marshal(node)
+		if err != nil {
+			return errors.Wrap(err, "Cannot format output as YAML")
+		}
+		fmt.Print(string(marshalled))
+	case OutputTable:
+		node, err := toNode(data)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		writeTable(w, node)
+		return w.Flush()
+	default:
+		text(os.Stdout)
+	}
+
+	return nil
+}
+
+// toNode converts data to a YAML node through its JSON encoding, so field names and order match the JSON output
+func toNode(data interface{}) (*yaml.Node, error) {
+	marshalled, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot format output")
+	}
+
+	var document yaml.Node
+	if err = yaml.Unmarshal(marshalled, &document); err != nil {
+		return nil, errors.Wrap(err, "Cannot format output")
+	}
+	node := document.Content[0]
+	resetStyle(node)
+
+	return node, nil
+}
+
+// resetStyle drops the flow and quoting styles the JSON source left on a node, so it renders as block YAML
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// writeTable writes a mapping as KEY/VALUE rows and a list of mappings as one row per item with a column per key
+func writeTable(w io.Writer, node *yaml.Node) {
+	switch {
+	case node.Kind == yaml.SequenceNode && len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode:
+		var columns []string
+		seen := map[string]bool{}
+		for _, item := range node.Content {
+			for i := 0; i < len(item.Content); i += 2 {
+				if key := item.Content[i].Value; !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+
+		for _, item := range node.Content {
+			row := make([]string, len(columns))
+			for i, column := range columns {
+				row[i] = cellValue(mappingValue(item, column))
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+	case node.Kind == yaml.MappingNode:
+		fmt.Fprintln(w, "KEY\tVALUE")
+		for i := 0; i < len(node.Content); i += 2 {
+			fmt.Fprintf(w, "%s\t%s\n", node.Content[i].Value, cellValue(node.Content[i+1]))
+		}
+	case node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			fmt.Fprintln(w, cellValue(item))
+		}
+	default:
+		fmt.Fprintln(w, cellValue(node))
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// cellValue renders a node on a single line. Nested values are written as compact YAML.
+func cellValue(node *yaml.Node) string {
+	if node == nil || node.Tag == "!!null" {
+		return ""
+	} else if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+
+	flow := *node
+	flow.Style = yaml.FlowStyle
+	marshalled, err := yaml.Marshal(&flow)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(marshalled))
+}
+
+func isTerminal(file *os.File) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
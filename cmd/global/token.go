@@ -0,0 +1,247 @@
+package global
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/config"
+	"github.com/hunoz/maroon/token"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// GetTokenChainFromAllOptions returns the token providers for the given context. A token source passed on the
+// command line always wins, otherwise the implicit sources are tried in the order set in the Maroon config.
+func GetTokenChainFromAllOptions(apiContext *config.ApiContext) (token.Chain, error) {
+	var explicit token.Chain
+	if viper.IsSet(FlagKey.Token) {
+		explicit = append(explicit, token.StaticProvider{Source: "--" + FlagKey.Token, Value: viper.GetString(FlagKey.Token)})
+	}
+	if viper.GetBool(FlagKey.TokenStdin) {
+		explicit = append(explicit, token.ReaderProvider{Source: "stdin", Reader: os.Stdin})
+	}
+	if viper.IsSet(FlagKey.TokenFile) {
+		explicit = append(explicit, token.FileProvider{Path: viper.GetString(FlagKey.TokenFile)})
+	}
+	if viper.IsSet(FlagKey.TokenCommand) {
+		explicit = append(explicit, token.CommandProvider{Command: viper.GetString(FlagKey.TokenCommand)})
+	}
+
+	if len(explicit) > 1 {
+		return nil, errors.New(fmt.Sprintf("Only one token source may be passed. Received %s", strings.Join(explicit.Names(), ", ")))
+	} else if len(explicit) == 1 {
+		return explicit, nil
+	}
+
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	tokenConfig := config.TokenConfig{}
+	if configuration.Token != nil {
+		tokenConfig = *configuration.Token
+	}
+
+	sources := tokenConfig.Sources
+	if len(sources) == 0 {
+		sources = config.DefaultTokenSources
+	}
+
+	tokenFile := tokenConfig.File
+	if value := os.Getenv(EnvKey.TokenFile); value != "" {
+		tokenFile = value
+	}
+
+	var chain token.Chain
+	for _, source := range sources {
+		switch source {
+		case "env":
+			chain = append(chain, token.EnvProvider{Variable: EnvKey.Token})
+		case "file":
+			chain = append(chain, token.FileProvider{Path: tokenFile})
+		case "command":
+			chain = append(chain, token.CommandProvider{Command: tokenConfig.Command})
+		case "spark":
+			chain = append(chain, token.SparkProvider{ConfigPath: apiContext.IdentitySource})
+		default:
+			return nil, errors.New(fmt.Sprintf("Unknown token source '%s' in Maroon config. Valid sources are %v", source, config.DefaultTokenSources))
+		}
+	}
+
+	return chain, nil
+}
+
+// tokenExpiredError is returned when the token has expired and could not be refreshed
+type tokenExpiredError struct {
+	err error
+}
+
+func (e *tokenExpiredError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tokenExpiredError) Unwrap() error {
+	return e.err
+}
+
+// IsTokenExpired reports whether err means the token for the Maroon API has expired and could not be refreshed.
+// Mistakes in the token configuration are not reported as expired tokens.
+func IsTokenExpired(err error) bool {
+	var expiredErr *tokenExpiredError
+	return errors.As(err, &expiredErr)
+}
+
+// GetTokenFromAllOptions returns the token used to authenticate to the given context, along with a function that
+// refreshes it when the Maroon API rejects it. The refresh function is nil when the token cannot be refreshed.
+func GetTokenFromAllOptions(apiContext *config.ApiContext) (string, func(ctx context.Context) (string, error), error) {
+	chain, err := GetTokenChainFromAllOptions(apiContext)
+	if err != nil {
+		return "", nil, err
+	}
+
+	value, source, err := chain.Token()
+	if errors.Is(err, token.ErrNoToken) {
+		return "", nil, errors.New(fmt.Sprintf("Token could not be found in %s. Please run 'spark auth' or pass a token with '--%s', '--%s', '--%s' or '--%s'", strings.Join(chain.Names(), ", "), FlagKey.Token, FlagKey.TokenFile, FlagKey.TokenStdin, FlagKey.TokenCommand))
+	} else if err != nil {
+		if strings.Contains(err.Error(), "spark init") {
+			return "", nil, errors.New("Spark has not been initialized. Please run 'spark init' to initialize Spark.")
+		}
+		return "", nil, err
+	}
+
+	Verbose("Using token from %s", source)
+	refresh, err := getTokenRefresherFromAllOptions(apiContext)
+	if err != nil {
+		return "", nil, err
+	}
+
+	expired, err := checkTokenExpiry(value, source, refresh == nil)
+	if err != nil {
+		return "", nil, err
+	} else if expired {
+		Verbose("Token from %s has expired, refreshing it", source)
+		if value, err = refresh(context.Background()); err != nil {
+			return "", nil, &tokenExpiredError{err: errors.Wrap(err, fmt.Sprintf("Token from %s has expired and could not be refreshed", source))}
+		}
+		if _, err = checkTokenExpiry(value, source, true); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return value, refresh, nil
+}
+
+// getTokenRefresherFromAllOptions returns a function that refreshes a token with the configured refresh command, then
+// reads it again from the token sources. It returns nil when no refresh command is configured, since a token can only
+// be refreshed by running one.
+func getTokenRefresherFromAllOptions(apiContext *config.ApiContext) (func(ctx context.Context) (string, error), error) {
+	// Tokens passed directly cannot change, so there is nothing to refresh
+	if viper.IsSet(FlagKey.Token) || viper.GetBool(FlagKey.TokenStdin) {
+		return nil, nil
+	}
+
+	configuration, err := config.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	configured := ""
+	if configuration.Token != nil {
+		configured = configuration.Token.RefreshCommand
+	}
+
+	refreshCommand := stringFromAllOptions(FlagKey.TokenRefresh, configured)
+	if refreshCommand == "" {
+		return nil, nil
+	}
+
+	return func(ctx context.Context) (string, error) {
+		Verbose("Refreshing token with '%s'", refreshCommand)
+		if err := token.RunRefreshCommand(ctx, refreshCommand, IsInteractive()); err != nil {
+			return "", err
+		}
+
+		chain, err := GetTokenChainFromAllOptions(apiContext)
+		if err != nil {
+			return "", err
+		}
+		value, _, err := chain.Token()
+		return value, err
+	}, nil
+}
+
+// IsInteractive reports whether commands may prompt the user. Prompting is never allowed with '--no-input', which
+// 'credentials print' sets because it runs as an AWS credential_process, or when stdin is not a terminal.
+func IsInteractive() bool {
+	if viper.GetBool(FlagKey.NoInput) {
+		return false
+	}
+
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// checkTokenExpiry reports whether the token has expired and warns when it is about to, so that an expired token is
+// reported clearly instead of as a failed Maroon API call. When failIfExpired is set, an expired token is an error.
+func checkTokenExpiry(value string, source string, failIfExpired bool) (bool, error) {
+	claims, err := token.ParseClaims(value)
+	if err != nil {
+		Verbose("Skipping token expiry check: %v", err.Error())
+		return false, nil
+	}
+
+	expiresAt := claims.ExpiresAt()
+	if expiresAt.IsZero() {
+		Verbose("Token from %s does not have an expiry", source)
+		return false, nil
+	}
+
+	now := time.Now()
+	remaining := expiresAt.Sub(now).Round(time.Second)
+	Verbose("Token from %s expires at %s (%v)", source, expiresAt.Local().Format(time.RFC1123), remaining)
+
+	refreshHint := "Please provide a new token"
+	if strings.HasPrefix(source, "Spark") {
+		refreshHint = "Please run 'spark auth' to refresh it"
+	}
+
+	if claims.Expired(now) {
+		if !failIfExpired {
+			return true, nil
+		}
+		return true, &tokenExpiredError{err: errors.New(fmt.Sprintf("Token from %s expired at %s. %s", source, expiresAt.Local().Format(time.RFC1123), refreshHint))}
+	}
+
+	warning, err := getTokenExpiryWarningFromAllOptions()
+	if err != nil {
+		return false, err
+	} else if warning > 0 && remaining <= warning {
+		fmt.Fprintln(os.Stderr, color.YellowString("Token from %s expires in %v. %s", source, remaining, refreshHint))
+	}
+
+	return false, nil
+}
+
+// getTokenExpiryWarningFromAllOptions returns how long before token expiry a warning is printed. Zero disables it.
+func getTokenExpiryWarningFromAllOptions() (time.Duration, error) {
+	if viper.IsSet(FlagKey.TokenWarning) {
+		return viper.GetDuration(FlagKey.TokenWarning), nil
+	}
+
+	configuration, err := config.GetConfig()
+	if err != nil || configuration.Token == nil || configuration.Token.ExpiryWarning == "" {
+		return 0, nil
+	}
+
+	warning, err := time.ParseDuration(configuration.Token.ExpiryWarning)
+	if err != nil {
+		return 0, errors.Wrap(err, fmt.Sprintf("Invalid token expiry warning '%s' in Maroon config", configuration.Token.ExpiryWarning))
+	}
+
+	return warning, nil
+}
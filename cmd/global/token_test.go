@@ -0,0 +1,119 @@
+package global
+
+import (
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// writeExpiredToken writes a token that expired in 1970 and returns its path
+func writeExpiredToken(t *testing.T) string {
+	t.Helper()
+
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"test","exp":1000}`))
+	tokenPath := filepath.Join(testHome, "token")
+	if err := os.WriteFile(tokenPath, []byte("e30."+claims+".sig"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return tokenPath
+}
+
+func TestNewClientFromAllOptionsTokenExpired(t *testing.T) {
+	tokenPath := writeExpiredToken(t)
+
+	tests := []struct {
+		name        string
+		config      string
+		flags       map[string]interface{}
+		wantExpired bool
+		wantMessage string
+	}{
+		{
+			name:        "refresh fails",
+			config:      `{}`,
+			flags:       map[string]interface{}{FlagKey.TokenFile: tokenPath, FlagKey.TokenRefresh: "exit 3"},
+			wantExpired: true,
+			wantMessage: "could not be refreshed",
+		},
+		{
+			name:        "no refresh command",
+			config:      `{}`,
+			flags:       map[string]interface{}{FlagKey.TokenFile: tokenPath},
+			wantExpired: true,
+			wantMessage: "expired at",
+		},
+		{
+			name:        "more than one token source",
+			config:      `{}`,
+			flags:       map[string]interface{}{FlagKey.Token: "token", FlagKey.TokenFile: tokenPath},
+			wantMessage: "Only one token source",
+		},
+		{
+			name:        "unknown token source",
+			config:      `{"Token":{"sources":["vault"]}}`,
+			wantMessage: "Unknown token source",
+		},
+		{
+			name:        "no token",
+			config:      `{"Token":{"sources":["env"]}}`,
+			wantMessage: "could not be found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			writeTestConfig(t, test.config)
+			t.Cleanup(viper.Reset)
+			viper.Set(FlagKey.NoInput, true)
+			for key, value := range test.flags {
+				viper.Set(key, value)
+			}
+
+			_, err := NewClientFromAllOptions(&config.ApiContext{Endpoint: "http://127.0.0.1:1"})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := IsTokenExpired(err); got != test.wantExpired {
+				t.Errorf("IsTokenExpired(%v) = %v, want %v", err, got, test.wantExpired)
+			}
+			if !strings.Contains(err.Error(), test.wantMessage) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestIsTokenExpired(t *testing.T) {
+	networkErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name            string
+		err             error
+		wantExpired     bool
+		wantUnavailable bool
+	}{
+		{"expired token", &tokenExpiredError{err: errors.New("Token expired")}, true, false},
+		{"wrapped expired token", errors.Wrap(&tokenExpiredError{err: errors.New("Token expired")}, "Error fetching credentials"), true, false},
+		{"token refresh failed on network", &tokenExpiredError{err: errors.Wrap(networkErr, "Token has expired and could not be refreshed")}, true, true},
+		{"other error", errors.New("Invalid request"), false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsTokenExpired(test.err); got != test.wantExpired {
+				t.Errorf("IsTokenExpired() = %v, want %v", got, test.wantExpired)
+			}
+			if got := client.IsUnavailable(test.err); got != test.wantUnavailable {
+				t.Errorf("client.IsUnavailable() = %v, want %v", got, test.wantUnavailable)
+			}
+		})
+	}
+}
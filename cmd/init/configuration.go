@@ -0,0 +1,11 @@
+package init
+
+var FlagKey = struct {
+	Overwrite     string
+	ApiUrl        string
+	ApiPathPrefix string
+}{
+	Overwrite:     "overwrite",
+	ApiUrl:        "api-url",
+	ApiPathPrefix: "api-path-prefix",
+}
@@ -0,0 +1,95 @@
+package init
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func prompt(label string, defaultValue string) string {
+	if defaultValue != "" {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", label)
+	}
+
+	value, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+func getApiUrlFromAllOptions() string {
+	apiUrl := viper.GetString(FlagKey.ApiUrl)
+	if apiUrl == "" && global.IsInteractive() {
+		apiUrl = prompt("Maroon API URL", "")
+	}
+	if apiUrl == "" {
+		color.Red("Maroon API URL cannot be empty. Pass it with '--%s'", FlagKey.ApiUrl)
+		os.Exit(1)
+	}
+	return apiUrl
+}
+
+// getApiPathPrefixFromAllOptions only prompts for the path prefix when Maroon is initialized interactively, so passing
+// '--api-url' is enough to initialize it from a script
+func getApiPathPrefixFromAllOptions(cmd *cobra.Command) string {
+	apiPathPrefix := viper.GetString(FlagKey.ApiPathPrefix)
+	if !cmd.Flags().Changed(FlagKey.ApiPathPrefix) && !cmd.Flags().Changed(FlagKey.ApiUrl) && global.IsInteractive() {
+		apiPathPrefix = prompt("Maroon API path prefix", config.DefaultApiPathPrefix)
+	}
+
+	// An empty prefix is stored as '/', since an unset prefix means the default one
+	if apiPathPrefix == "" {
+		apiPathPrefix = "/"
+	}
+	return apiPathPrefix
+}
+
+var InitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Initialize the Maroon CLI's configuration",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(FlagKey.Overwrite, cmd.Flags().Lookup(FlagKey.Overwrite))
+		viper.BindPFlag(FlagKey.ApiUrl, cmd.Flags().Lookup(FlagKey.ApiUrl))
+		viper.BindPFlag(FlagKey.ApiPathPrefix, cmd.Flags().Lookup(FlagKey.ApiPathPrefix))
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		overwrite := viper.GetBool(FlagKey.Overwrite)
+		if configuration, _ := config.MaroonIsInitialized(); configuration != nil && !overwrite {
+			color.Cyan("Maroon is already initialized with endpoint '%s'. Use '--%s' to replace it", configuration.Endpoint, FlagKey.Overwrite)
+			os.Exit(0)
+		}
+
+		apiUrl := getApiUrlFromAllOptions()
+		apiPathPrefix := getApiPathPrefixFromAllOptions(cmd)
+
+		if _, err := config.JoinApiUrl(apiUrl, apiPathPrefix); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		if err := config.UpdateEndpoint(apiUrl, apiPathPrefix); err != nil {
+			color.Red("Error updating config: %v", err.Error())
+			os.Exit(1)
+		}
+
+		color.Green("Initialized Maroon with endpoint '%s'", apiUrl)
+	},
+}
+
+func init() {
+	InitCmd.Flags().BoolP(FlagKey.Overwrite, "o", false, "Overwrite current configuration")
+	InitCmd.Flags().StringP(FlagKey.ApiUrl, "u", "", "Base URL of the Maroon API (i.e. https://api.maroon.example.com)")
+	InitCmd.Flags().String(FlagKey.ApiPathPrefix, config.DefaultApiPathPrefix, "Path prefix that Maroon API operations are served under. Use '/' for an API served at the root of its URL")
+}
@@ -14,11 +14,18 @@ import (
 var AddProfileCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a profile to the Maroon config",
+	Long: "Add a profile to the Maroon config and a credential_process for it to the AWS config. With '--context', the " +
+		"profile belongs to that Maroon API context and its credentials are always fetched from it. '--session-duration' " +
+		"and '--refresh-before' override the Maroon config for this profile.",
+	Example: "  maroon profile add -p dev -i 123456789012 -r Developer --region us-east-1\n" +
+		"  maroon profile add -p staging -i 123456789012 -r Developer --region us-east-1 --context staging\n" +
+		"  maroon profile add -p ops -i 123456789012 -r Operator --region us-east-1 --session-duration 4h --refresh-before 30m",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		viper.BindPFlag(AddProfileFlagKey.AccountId, cmd.Flags().Lookup(AddProfileFlagKey.AccountId))
 		viper.BindPFlag(AddProfileFlagKey.Role, cmd.Flags().Lookup(AddProfileFlagKey.Role))
 		viper.BindPFlag(AddProfileFlagKey.Region, cmd.Flags().Lookup(AddProfileFlagKey.Region))
 		viper.BindPFlag(AddProfileFlagKey.ProfileName, cmd.Flags().Lookup(AddProfileFlagKey.ProfileName))
+		viper.BindPFlag(AddProfileFlagKey.Production, cmd.Flags().Lookup(AddProfileFlagKey.Production))
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		accountId := viper.GetString(AddProfileFlagKey.AccountId)
@@ -39,16 +46,30 @@ var AddProfileCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		// Not bound with viper, so MAROON_CONTEXT, which selects the context of a single command, is not recorded
+		contextName, _ := cmd.Flags().GetString(AddProfileFlagKey.Context)
+		// Likewise for MAROON_SESSION_DURATION and MAROON_REFRESH_BEFORE
+		sessionDuration, _ := cmd.Flags().GetString(AddProfileFlagKey.SessionDuration)
+		refreshBefore, _ := cmd.Flags().GetString(AddProfileFlagKey.RefreshBefore)
+
 		err := config.AddProfile(profileName, config.Profile{
-			AccountId:    accountId,
-			RoleToAssume: roleName,
-			Region:       region,
+			AccountId:       accountId,
+			RoleToAssume:    roleName,
+			Region:          region,
+			Context:         contextName,
+			Production:      viper.GetBool(AddProfileFlagKey.Production),
+			SessionDuration: sessionDuration,
+			RefreshBefore:   refreshBefore,
 		})
 		if err != nil {
 			color.Red(err.Error())
 			os.Exit(1)
 		}
-		color.Green("Added profile '%s'", profileName)
+		if contextName != "" {
+			color.Green("Added profile '%s' in context '%s'", profileName, contextName)
+		} else {
+			color.Green("Added profile '%s'", profileName)
+		}
 	},
 }
 
@@ -61,4 +82,8 @@ func init() {
 	AddProfileCmd.MarkFlagRequired(AddProfileFlagKey.Region)
 	AddProfileCmd.Flags().StringP(AddProfileFlagKey.ProfileName, "p", "", "Name to give the profile. Only used by Maroon, must only contain alphanumeric characters and the following special characters: '-'")
 	AddProfileCmd.MarkFlagRequired(AddProfileFlagKey.ProfileName)
+	AddProfileCmd.Flags().String(AddProfileFlagKey.Context, "", "Maroon API context the profile belongs to. Defaults to the current context at the time credentials are fetched")
+	AddProfileCmd.Flags().String(AddProfileFlagKey.SessionDuration, "", "Session duration to request for the profile, i.e. 2h or 3600. Between 15m and 12h")
+	AddProfileCmd.Flags().String(AddProfileFlagKey.RefreshBefore, "", "Refresh cached credentials of the profile that expire within this duration, i.e. 30m. Must be shorter than the session duration")
+	AddProfileCmd.Flags().Bool(AddProfileFlagKey.Production, false, "Mark the profile as production, so destructive operations such as revoking sessions ask for confirmation")
 }
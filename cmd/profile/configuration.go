@@ -1,15 +1,23 @@
 package profile
 
 var AddProfileFlagKey = struct {
-	ProfileName string
-	AccountId   string
-	Region      string
-	Role        string
+	ProfileName     string
+	AccountId       string
+	Region          string
+	Role            string
+	Production      string
+	Context         string
+	SessionDuration string
+	RefreshBefore   string
 }{
-	ProfileName: "profile-name",
-	AccountId:   "account-id",
-	Region:      "region",
-	Role:        "role",
+	ProfileName:     "profile-name",
+	AccountId:       "account-id",
+	Region:          "region",
+	Role:            "role",
+	Production:      "production",
+	Context:         "context",
+	SessionDuration: "session-duration",
+	RefreshBefore:   "refresh-before",
 }
 
 var RemoveProfileFlagKey = struct {
@@ -2,17 +2,34 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/client"
+	"github.com/hunoz/maroon/cmd/agent"
+	configcmd "github.com/hunoz/maroon/cmd/config"
 	consoleurl "github.com/hunoz/maroon/cmd/console-url"
+	maroonContext "github.com/hunoz/maroon/cmd/context"
 	"github.com/hunoz/maroon/cmd/credentials"
+	execcmd "github.com/hunoz/maroon/cmd/exec"
+	"github.com/hunoz/maroon/cmd/global"
+	cmdInit "github.com/hunoz/maroon/cmd/init"
 	"github.com/hunoz/maroon/cmd/profile"
+	"github.com/hunoz/maroon/cmd/serve"
 	"github.com/hunoz/maroon/cmd/update"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var RootCmd = &cobra.Command{
 	Use:   "maroon",
 	Short: "Manage AWS profiles fetch credentials using Maroon API",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := global.ConfigureOutput(); err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		version, err := cmd.Flags().GetBool("version")
 		if err != nil {
@@ -29,5 +46,66 @@ var RootCmd = &cobra.Command{
 
 func init() {
 	RootCmd.Flags().BoolP("version", "v", false, "Current version of Maroon")
-	RootCmd.AddCommand(consoleurl.ConsoleUrlCmd, update.UpdateCmd, profile.ProfileCmd, credentials.CredentialsCmd)
+	RootCmd.PersistentFlags().String(global.FlagKey.Context, "", fmt.Sprintf("Maroon API context to use instead of the profile's or current context. May also be set with %s", global.EnvKey.Context))
+	viper.BindPFlag(global.FlagKey.Context, RootCmd.PersistentFlags().Lookup(global.FlagKey.Context))
+	viper.BindEnv(global.FlagKey.Context, global.EnvKey.Context)
+	RootCmd.PersistentFlags().String(global.FlagKey.Endpoint, "", fmt.Sprintf("Maroon API endpoint to use instead of the one set by 'maroon init'. May also be set with %s", global.EnvKey.Endpoint))
+	viper.BindPFlag(global.FlagKey.Endpoint, RootCmd.PersistentFlags().Lookup(global.FlagKey.Endpoint))
+	viper.BindEnv(global.FlagKey.Endpoint, global.EnvKey.Endpoint)
+	RootCmd.PersistentFlags().Duration(global.FlagKey.Timeout, client.DefaultTimeout, fmt.Sprintf("Timeout for each Maroon API request. May also be set with %s", global.EnvKey.Timeout))
+	viper.BindPFlag(global.FlagKey.Timeout, RootCmd.PersistentFlags().Lookup(global.FlagKey.Timeout))
+	viper.BindEnv(global.FlagKey.Timeout, global.EnvKey.Timeout)
+	RootCmd.PersistentFlags().Int(global.FlagKey.MaxAttempts, client.DefaultRetryPolicy.MaxAttempts, fmt.Sprintf("Maximum attempts for each Maroon API call, including retries. May also be set with %s or in the Maroon config", global.EnvKey.MaxAttempts))
+	viper.BindPFlag(global.FlagKey.MaxAttempts, RootCmd.PersistentFlags().Lookup(global.FlagKey.MaxAttempts))
+	viper.BindEnv(global.FlagKey.MaxAttempts, global.EnvKey.MaxAttempts)
+	RootCmd.PersistentFlags().Duration(global.FlagKey.RetryDeadline, client.DefaultRetryPolicy.Deadline, fmt.Sprintf("Total time allowed for each Maroon API call, including retries. May also be set with %s or in the Maroon config", global.EnvKey.RetryDeadline))
+	viper.BindPFlag(global.FlagKey.RetryDeadline, RootCmd.PersistentFlags().Lookup(global.FlagKey.RetryDeadline))
+	viper.BindEnv(global.FlagKey.RetryDeadline, global.EnvKey.RetryDeadline)
+	for _, flag := range []struct {
+		key   string
+		env   string
+		usage string
+	}{
+		{global.FlagKey.Proxy, global.EnvKey.Proxy, "Proxy URL for all requests, overriding HTTP_PROXY and HTTPS_PROXY"},
+		{global.FlagKey.NoProxy, global.EnvKey.NoProxy, "Comma separated hosts, domains and CIDRs that bypass the proxy, overriding NO_PROXY"},
+		{global.FlagKey.CABundle, global.EnvKey.CABundle, "Path to a PEM bundle of certificate authorities to trust in addition to the system ones"},
+		{global.FlagKey.ClientCert, global.EnvKey.ClientCert, "Path to a PEM client certificate for mutual TLS"},
+		{global.FlagKey.ClientKey, global.EnvKey.ClientKey, "Path to the PEM private key of the client certificate"},
+	} {
+		RootCmd.PersistentFlags().String(flag.key, "", fmt.Sprintf("%s. May also be set with %s or in the Maroon config", flag.usage, flag.env))
+		viper.BindPFlag(flag.key, RootCmd.PersistentFlags().Lookup(flag.key))
+		viper.BindEnv(flag.key, flag.env)
+	}
+	RootCmd.PersistentFlags().String(global.FlagKey.Token, "", fmt.Sprintf("Token to authenticate to Maroon API with. Visible to other users in the process list, prefer --%s or --%s", global.FlagKey.TokenFile, global.FlagKey.TokenStdin))
+	viper.BindPFlag(global.FlagKey.Token, RootCmd.PersistentFlags().Lookup(global.FlagKey.Token))
+	RootCmd.PersistentFlags().String(global.FlagKey.TokenFile, "", "Path to a file containing the token to authenticate to Maroon API with")
+	viper.BindPFlag(global.FlagKey.TokenFile, RootCmd.PersistentFlags().Lookup(global.FlagKey.TokenFile))
+	RootCmd.PersistentFlags().Bool(global.FlagKey.TokenStdin, false, "Read the token to authenticate to Maroon API with from stdin")
+	viper.BindPFlag(global.FlagKey.TokenStdin, RootCmd.PersistentFlags().Lookup(global.FlagKey.TokenStdin))
+	RootCmd.PersistentFlags().String(global.FlagKey.TokenCommand, "", "Command whose output is the token to authenticate to Maroon API with")
+	viper.BindPFlag(global.FlagKey.TokenCommand, RootCmd.PersistentFlags().Lookup(global.FlagKey.TokenCommand))
+	RootCmd.PersistentFlags().Duration(global.FlagKey.TokenWarning, 0, fmt.Sprintf("Warn when the token expires within this duration (i.e. 30m). May also be set with %s or in the Maroon config", global.EnvKey.TokenWarning))
+	viper.BindPFlag(global.FlagKey.TokenWarning, RootCmd.PersistentFlags().Lookup(global.FlagKey.TokenWarning))
+	viper.BindEnv(global.FlagKey.TokenWarning, global.EnvKey.TokenWarning)
+	RootCmd.PersistentFlags().String(global.FlagKey.TokenRefresh, "", fmt.Sprintf("Command that refreshes an expired or rejected token, i.e. 'spark auth'. May also be set with %s or in the Maroon config", global.EnvKey.TokenRefresh))
+	viper.BindPFlag(global.FlagKey.TokenRefresh, RootCmd.PersistentFlags().Lookup(global.FlagKey.TokenRefresh))
+	viper.BindEnv(global.FlagKey.TokenRefresh, global.EnvKey.TokenRefresh)
+	RootCmd.PersistentFlags().Bool(global.FlagKey.NoInput, false, "Never prompt for input, i.e. when refreshing a token")
+	viper.BindPFlag(global.FlagKey.NoInput, RootCmd.PersistentFlags().Lookup(global.FlagKey.NoInput))
+	RootCmd.PersistentFlags().Bool(global.FlagKey.Offline, false, fmt.Sprintf("Never call the Maroon API and only use unexpired cached credentials. May also be set with %s", global.EnvKey.Offline))
+	viper.BindPFlag(global.FlagKey.Offline, RootCmd.PersistentFlags().Lookup(global.FlagKey.Offline))
+	viper.BindEnv(global.FlagKey.Offline, global.EnvKey.Offline)
+	viper.BindEnv(global.FlagKey.Duration, global.EnvKey.Duration)
+	viper.BindEnv(global.FlagKey.RefreshBefore, global.EnvKey.RefreshBefore)
+	RootCmd.PersistentFlags().Bool(global.FlagKey.Verbose, false, "Print diagnostic messages to stderr")
+	viper.BindPFlag(global.FlagKey.Verbose, RootCmd.PersistentFlags().Lookup(global.FlagKey.Verbose))
+	RootCmd.PersistentFlags().String(global.FlagKey.Output, "", fmt.Sprintf("Output format of the data a command prints, one of json, yaml, table or text. May also be set with %s", global.EnvKey.Output))
+	viper.BindPFlag(global.FlagKey.Output, RootCmd.PersistentFlags().Lookup(global.FlagKey.Output))
+	viper.BindEnv(global.FlagKey.Output, global.EnvKey.Output)
+	RootCmd.PersistentFlags().String(global.FlagKey.Format, "", "Go template to render the data a command prints with, i.e. '{{range .Profiles}}{{.ProfileName}} {{end}}'. Takes precedence over --output")
+	viper.BindPFlag(global.FlagKey.Format, RootCmd.PersistentFlags().Lookup(global.FlagKey.Format))
+	RootCmd.PersistentFlags().Bool(global.FlagKey.NoColor, false, fmt.Sprintf("Disable colored messages. Also disabled when %s is set or stderr is not a terminal", global.EnvKey.NoColor))
+	viper.BindPFlag(global.FlagKey.NoColor, RootCmd.PersistentFlags().Lookup(global.FlagKey.NoColor))
+
+	RootCmd.AddCommand(cmdInit.InitCmd, configcmd.ConfigCmd, maroonContext.ContextCmd, consoleurl.ConsoleUrlCmd, update.UpdateCmd, profile.ProfileCmd, credentials.CredentialsCmd, agent.AgentCmd, serve.ServeCmd, execcmd.ExecCmd, credentials.WhoamiCmd)
 }
@@ -0,0 +1,19 @@
+package serve
+
+var FlagKey = struct {
+	ProfileName string
+	ECS         string
+	IMDS        string
+	Address     string
+	Port        string
+	AllowRemote string
+	HopLimit    string
+}{
+	ProfileName: "profile-name",
+	ECS:         "ecs",
+	IMDS:        "imds",
+	Address:     "address",
+	Port:        "port",
+	AllowRemote: "allow-remote",
+	HopLimit:    "hop-limit",
+}
@@ -0,0 +1,182 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/fatih/color"
+	"github.com/hunoz/maroon/cmd/credentials"
+	"github.com/hunoz/maroon/cmd/global"
+	"github.com/hunoz/maroon/config"
+	"github.com/hunoz/maroon/metadata"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var ServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a profile's credentials to AWS SDKs over a local credentials endpoint",
+	Long: "Serve a profile's credentials over a local HTTP endpoint that AWS SDKs read credentials from. With '--ecs', the " +
+		"endpoint behaves like the ECS container credentials endpoint and the environment variables that point SDKs at it are " +
+		"printed. With '--imds', it behaves like the EC2 instance metadata service with IMDSv2 required, serving the profile's " +
+		"role credentials and region. Credentials are refreshed as they are requested.",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		viper.BindPFlag(FlagKey.ProfileName, cmd.Flags().Lookup(FlagKey.ProfileName))
+		viper.BindPFlag(FlagKey.ECS, cmd.Flags().Lookup(FlagKey.ECS))
+		viper.BindPFlag(FlagKey.IMDS, cmd.Flags().Lookup(FlagKey.IMDS))
+		viper.BindPFlag(FlagKey.Address, cmd.Flags().Lookup(FlagKey.Address))
+		viper.BindPFlag(FlagKey.Port, cmd.Flags().Lookup(FlagKey.Port))
+		viper.BindPFlag(FlagKey.AllowRemote, cmd.Flags().Lookup(FlagKey.AllowRemote))
+		viper.BindPFlag(FlagKey.HopLimit, cmd.Flags().Lookup(FlagKey.HopLimit))
+		// Nobody is around to answer a prompt while the server runs
+		viper.Set(global.FlagKey.NoInput, true)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		profileName := viper.GetString(FlagKey.ProfileName)
+		profile, err := config.GetProfile(profileName)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		ecs, imds := viper.GetBool(FlagKey.ECS), viper.GetBool(FlagKey.IMDS)
+		if ecs == imds {
+			color.Red("Choose the endpoint to serve with either '--%s' or '--%s'", FlagKey.ECS, FlagKey.IMDS)
+			os.Exit(1)
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(viper.GetString(FlagKey.Address), strconv.Itoa(viper.GetInt(FlagKey.Port))))
+		if err != nil {
+			color.Red("Cannot listen on %s:%d: %v", viper.GetString(FlagKey.Address), viper.GetInt(FlagKey.Port), err.Error())
+			os.Exit(1)
+		}
+
+		// Anyone who can reach the endpoint gets the credentials, IMDS asks for no secret at all
+		if !viper.GetBool(FlagKey.AllowRemote) && !isLocalOnly(listener.Addr().(*net.TCPAddr).IP) {
+			listener.Close()
+			color.Red("Refusing to serve credentials on %s, which other hosts can reach. Listen on a loopback address or pass '--%s'", listener.Addr(), FlagKey.AllowRemote)
+			os.Exit(1)
+		}
+
+		var handler http.Handler
+		if imds {
+			hopLimit := viper.GetInt(FlagKey.HopLimit)
+			if hopLimit < 1 || hopLimit > 64 {
+				color.Red("Hop limit must be between 1 and 64")
+				os.Exit(1)
+			}
+			listener = metadata.LimitHops(listener, hopLimit)
+			handler = metadata.NewIMDSHandler(profile.RoleToAssume, profile.Region, profile.AccountId, profileCredentials(profileName))
+
+			fmt.Printf("export AWS_EC2_METADATA_SERVICE_ENDPOINT=http://%s/\n", advertisedAddress(listener))
+			fmt.Println("unset AWS_EC2_METADATA_DISABLED")
+		} else {
+			authToken, err := metadata.NewToken()
+			if err != nil {
+				color.Red("Cannot generate authorization token: %v", err.Error())
+				os.Exit(1)
+			}
+
+			roleArn := fmt.Sprintf("arn:aws:iam::%s:role/%s", profile.AccountId, profile.RoleToAssume)
+			handler = metadata.NewECSHandler(authToken, roleArn, profileCredentials(profileName))
+
+			fmt.Printf("export AWS_CONTAINER_CREDENTIALS_FULL_URI=http://%s%s\n", advertisedAddress(listener), metadata.ECSCredentialsPath)
+			fmt.Printf("export AWS_CONTAINER_AUTHORIZATION_TOKEN=%s\n", authToken)
+		}
+
+		serve(listener, handler, fmt.Sprintf("Serving credentials for profile '%s' on %s. Press Ctrl+C to stop", profileName, listener.Addr()))
+	},
+}
+
+// profileCredentials returns a function that resolves a profile's credentials one request at a time, since resolving
+// them reads and writes shared config and cache files
+func profileCredentials(profileName string) metadata.CredentialsFunc {
+	var mu sync.Mutex
+	return func() (types.Credentials, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return credentials.ActiveCredentials(profileName)
+	}
+}
+
+// advertisedAddress returns the address clients should use for listener. Servers bound to every interface are
+// advertised on the loopback address, since AWS SDKs only accept plain HTTP endpoints on loopback addresses.
+func advertisedAddress(listener net.Listener) string {
+	address := listener.Addr().(*net.TCPAddr)
+	if address.IP.IsUnspecified() {
+		return net.JoinHostPort("127.0.0.1", strconv.Itoa(address.Port))
+	}
+	return address.String()
+}
+
+// isLocalOnly reports whether ip can only be reached from this host, because it is a loopback address or is assigned
+// to a loopback interface, i.e. 169.254.169.254 added to lo for IMDS
+func isLocalOnly(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return true
+	} else if ip.IsUnspecified() {
+		return false
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return false
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+
+		addresses, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			if network, ok := address.(*net.IPNet); ok && network.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serve serves handler on listener until interrupted
+func serve(listener net.Listener, handler http.Handler, message string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintln(os.Stderr, color.GreenString(message))
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		color.Red("Error serving credentials: %v", err.Error())
+		os.Exit(1)
+	}
+}
+
+func init() {
+	ServeCmd.Flags().StringP(FlagKey.ProfileName, "p", "", "Profile name")
+	ServeCmd.MarkFlagRequired(FlagKey.ProfileName)
+	ServeCmd.Flags().Bool(FlagKey.ECS, false, "Serve credentials like the ECS container credentials endpoint")
+	ServeCmd.Flags().Bool(FlagKey.IMDS, false, "Serve credentials and region like the EC2 instance metadata service (IMDSv2)")
+	ServeCmd.Flags().String(FlagKey.Address, "127.0.0.1", "Address to listen on")
+	ServeCmd.Flags().Int(FlagKey.Port, 0, "Port to listen on. A free port is chosen when 0")
+	ServeCmd.Flags().Bool(FlagKey.AllowRemote, false, "Allow listening on an address other hosts can reach. Anyone who can reach it gets the profile's credentials")
+	ServeCmd.Flags().Int(FlagKey.HopLimit, metadata.DefaultIMDSHopLimit, "IP TTL of IMDS responses, like the EC2 metadata hop limit. Use 2 to serve containers on a bridge network")
+}
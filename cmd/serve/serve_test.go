@@ -0,0 +1,28 @@
+package serve
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsLocalOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"127.0.0.1", true},
+		{"127.0.0.53", true},
+		{"::1", true},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"192.0.2.10", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			if got := isLocalOnly(net.ParseIP(test.address)); got != test.want {
+				t.Errorf("isLocalOnly(%s) = %v, want %v", test.address, got, test.want)
+			}
+		})
+	}
+}
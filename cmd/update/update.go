@@ -4,11 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"runtime"
 	"strings"
 
+	"github.com/hunoz/maroon/cmd/global"
 	"github.com/spf13/cobra"
 
 	"github.com/fatih/color"
@@ -29,7 +29,13 @@ type Error struct {
 
 func CmdIsLatestVersion() (string, bool) {
 	currentVersion := strings.Split(CmdVersion, "v")[1]
-	response, err := http.Get("https://api.github.com/repos/hunoz/maroon-cli/releases/latest")
+	httpClient, err := global.NewHTTPClientFromAllOptions(0)
+	if err != nil {
+		color.Red(err.Error())
+		os.Exit(1)
+	}
+
+	response, err := httpClient.Get("https://api.github.com/repos/hunoz/maroon-cli/releases/latest")
 	if err != nil {
 		color.Red("Error fetching latest release: %v", err.Error())
 		os.Exit(1)
@@ -90,7 +96,13 @@ var UpdateCmd = &cobra.Command{
 		assetFilename := fmt.Sprintf("maroon-%v-%v", runtime.GOOS, runtime.GOARCH)
 		downloadUrl := fmt.Sprintf("https://github.com/hunoz/maroon-cli/releases/download/%v/%v", latestVersion, assetFilename)
 
-		response, err := http.Get(downloadUrl)
+		httpClient, err := global.NewHTTPClientFromAllOptions(0)
+		if err != nil {
+			color.Red(err.Error())
+			os.Exit(1)
+		}
+
+		response, err := httpClient.Get(downloadUrl)
 		if err != nil {
 			color.Red("Error downloading latest version: %v", err.Error())
 			os.Exit(1)
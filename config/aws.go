@@ -3,58 +3,29 @@ package config
 import (
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
-	"github.com/hunoz/spark/homedir"
 	"github.com/pkg/errors"
 	"gopkg.in/ini.v1"
 )
 
-// GetOrCreateAwsConfigFile looks for the aws credentials in the default path: '~/.aws/credentials'.
-// In case the file does not exists it will attempt to create one.
-func GetOrCreateAwsConfigFile() (*os.File, error) {
-	homeDir, err := homedir.Dir()
-
-	if err != nil {
-		return nil, errors.New("Unable to find the aws folder. \n" + err.Error())
-	}
-
-	awsCredentialsPath := filepath.Join(homeDir, ".aws", "config")
-	if err = os.MkdirAll(filepath.Dir(awsCredentialsPath), 0755); err != nil {
-		return nil, errors.New("Unable to create config path. \n" + err.Error())
-	}
-	return os.OpenFile(awsCredentialsPath, os.O_RDONLY|os.O_CREATE, 0600)
-}
-
 // AddCredentialProcess adds a credential_process key and value to the aws config under the given profile
 func AddCredentialProcess(profile string, region string) error {
-	awsConfig, err := GetOrCreateAwsConfigFile()
-	if err != nil {
-		return errors.Wrap(err, "Failed to get or create Maroon credentials file")
-	}
-	defer awsConfig.Close()
-
-	tmpFile, err := os.CreateTemp("", "aws")
+	awsConfig, err := GetManagedFile(ManagedAwsConfig)
 	if err != nil {
-		return errors.Wrap(err, "Failed to create temporary Maroon file")
+		return err
 	}
-	defer tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
 
-	err = updateAwsConfig(profile, region, awsConfig, tmpFile)
+	err = UpdateFile(awsConfig, func(in io.Reader, dest io.Writer) error {
+		return updateAwsConfig(profile, region, in, dest)
+	})
 	if err != nil {
-		return errors.Wrap(err, "Failed to update Maroon credentials file")
+		return errors.Wrap(err, "Failed to update AWS config file")
 	}
 
-	tmpFile.Sync()
-
-	// On windows replace only works if both files have been closed before calling the method
-	awsConfig.Close()
-	tmpFile.Close()
-
-	return replace(awsConfig, tmpFile)
+	return nil
 }
 
 func updateAwsConfigSection(section *ini.Section, profile string, region string) {
@@ -74,88 +45,149 @@ func updateAwsConfig(profile string, region string, in io.Reader, dest io.Writer
 	return writeTo(dest, cfg)
 }
 
-// GetOrCreateAwsCredentialsFile looks for the aws credentials in the default path: '~/.aws/credentials'.
-// In case the file does not exists it will attempt to create one.
-func GetOrCreateAwsCredentialsFile() (*os.File, error) {
-	homeDir, err := homedir.Dir()
+// Keys Maroon writes to a section of the AWS credentials file next to the credentials. AWS SDKs ignore them.
+const (
+	AwsExpirationKey = "x_security_token_expires"
+	AwsProfileKey    = "x_maroon_profile"
+)
 
-	if err != nil {
-		return nil, errors.New("Unable to find the aws folder. \n" + err.Error())
-	}
+// ErrStaticCredentials is returned by UpdateAwsCredentialsFile when a section it would overwrite holds long-term keys
+var ErrStaticCredentials = errors.New("Section holds long-term access keys")
 
-	awsCredentialsPath := filepath.Join(homeDir, ".aws", "credentials")
-	if err = os.MkdirAll(filepath.Dir(awsCredentialsPath), 0755); err != nil {
-		return nil, errors.New("Unable to create config path. \n" + err.Error())
-	}
-	return os.OpenFile(awsCredentialsPath, os.O_RDONLY|os.O_CREATE, 0600)
+// AwsCredentialsSection is a section of the AWS credentials file and the credentials of the Maroon profile to write
+// to it
+type AwsCredentialsSection struct {
+	Name        string
+	ProfileName string
+	Credentials types.Credentials
 }
 
-// UpdateAwsCredentialsFile creates/updates the aws credentials file with the profile credentials received as parameter.
-// It assumes the default path for the credentials file, which is '~/.aws/credentials'.
-func UpdateAwsCredentialsFile(credentials types.Credentials) error {
-	awsFile, err := GetOrCreateAwsCredentialsFile()
+// UpdateAwsCredentialsFile writes credentials to sections of the AWS credentials file, '~/.aws/credentials', in a
+// single update. Sections holding long-term keys are only overwritten with force, otherwise nothing is written and
+// ErrStaticCredentials is returned. The previous file is backed up, and the names of overwritten sections that held
+// long-term keys are returned.
+func UpdateAwsCredentialsFile(sections []AwsCredentialsSection, force bool) ([]string, error) {
+	awsCredentials, err := GetManagedFile(ManagedAwsCredentials)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer awsFile.Close()
 
-	tmpFile, err := os.CreateTemp("", "aws")
-	if err != nil {
+	var static []string
+	err = UpdateFile(awsCredentials, func(in io.Reader, dest io.Writer) error {
+		static, err = updateCredentials(sections, force, in, dest)
 		return err
-	}
-	defer tmpFile.Close()
-	defer os.Remove(tmpFile.Name())
-
-	err = updateCredentials(credentials, awsFile, tmpFile)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tmpFile.Sync()
-
-	// On windows replace only works if both files have been closed before calling the method
-	awsFile.Close()
-	tmpFile.Close()
-
-	return replace(awsFile, tmpFile)
+	return static, nil
 }
 
-func updateCredentials(credentials types.Credentials, in io.Reader, dest io.Writer) error {
+func updateCredentials(sections []AwsCredentialsSection, force bool, in io.Reader, dest io.Writer) ([]string, error) {
 	cfg, err := ini.Load(in)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	updateSection(cfg.Section("default"), credentials)
+	var static []string
+	for _, section := range sections {
+		if existing, err := cfg.GetSection(section.Name); err == nil && holdsStaticCredentials(existing) {
+			static = append(static, section.Name)
+		}
+	}
+	if len(static) > 0 && !force {
+		return nil, errors.Wrap(ErrStaticCredentials, fmt.Sprintf("Will not overwrite '%s'", strings.Join(static, "', '")))
+	}
 
-	return writeTo(dest, cfg)
-}
+	for _, section := range sections {
+		updateSection(cfg.Section(section.Name), section.ProfileName, section.Credentials)
+	}
 
-func updateSection(section *ini.Section, credentials types.Credentials) {
-	section.Key("aws_access_key_id").SetValue(*credentials.AccessKeyId)
-	section.Key("aws_secret_access_key").SetValue(*credentials.SecretAccessKey)
-	section.Key("aws_session_token").SetValue(*credentials.SessionToken)
+	return static, writeTo(dest, cfg)
 }
 
-// replace the file 'toReplace' with the 'replacement' file
-func replace(toReplace *os.File, replacement *os.File) error {
-	err := os.Rename(replacement.Name(), toReplace.Name())
+// RemoveAwsCredentialsSections removes the sections Maroon wrote to the AWS credentials file for which remove returns
+// true, and returns them. The file is left untouched when no section matches.
+func RemoveAwsCredentialsSections(remove func(section AwsCredentialsSection) bool) ([]AwsCredentialsSection, error) {
+	awsCredentials, err := GetManagedFile(ManagedAwsCredentials)
 	if err != nil {
-		// rename can fail if the files are on different volumes
-		in, err := os.Open(replacement.Name())
+		return nil, err
+	}
+
+	var removed []AwsCredentialsSection
+	err = UpdateFile(awsCredentials, func(in io.Reader, dest io.Writer) error {
+		current, err := io.ReadAll(in)
 		if err != nil {
 			return err
 		}
-		defer in.Close()
-		out, err := os.OpenFile(toReplace.Name(), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+
+		cfg, err := ini.Load(current)
 		if err != nil {
 			return err
 		}
-		defer out.Close()
-		_, err = io.Copy(out, in)
-		return err
+
+		for _, section := range cfg.Sections() {
+			if !section.HasKey(AwsProfileKey) {
+				continue
+			}
+
+			written := readSection(section)
+			if remove(written) {
+				cfg.DeleteSection(section.Name())
+				removed = append(removed, written)
+			}
+		}
+
+		if len(removed) == 0 {
+			_, err = dest.Write(current)
+			return err
+		}
+		return writeTo(dest, cfg)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to update AWS credentials file")
 	}
-	return err
+
+	return removed, nil
+}
+
+// readSection returns the credentials Maroon wrote to a section of the AWS credentials file
+func readSection(section *ini.Section) AwsCredentialsSection {
+	accessKeyId := section.Key("aws_access_key_id").String()
+	written := AwsCredentialsSection{
+		Name:        section.Name(),
+		ProfileName: section.Key(AwsProfileKey).String(),
+		Credentials: types.Credentials{AccessKeyId: &accessKeyId},
+	}
+	if expiration, err := time.Parse(time.RFC3339, section.Key(AwsExpirationKey).String()); err == nil {
+		written.Credentials.Expiration = &expiration
+	}
+
+	return written
+}
+
+// holdsStaticCredentials reports whether a section holds access keys that Maroon did not write and that are not
+// temporary, i.e. the keys of an IAM user
+func holdsStaticCredentials(section *ini.Section) bool {
+	if section.HasKey(AwsProfileKey) || !section.HasKey("aws_access_key_id") {
+		return false
+	}
+
+	return !section.HasKey("aws_session_token") || strings.HasPrefix(section.Key("aws_access_key_id").String(), "AKIA")
+}
+
+func updateSection(section *ini.Section, profileName string, credentials types.Credentials) {
+	section.Key("aws_access_key_id").SetValue(*credentials.AccessKeyId)
+	section.Key("aws_secret_access_key").SetValue(*credentials.SecretAccessKey)
+	section.Key("aws_session_token").SetValue(*credentials.SessionToken)
+	// An expiration left from earlier credentials would belong to keys that are no longer in the section
+	if credentials.Expiration != nil {
+		section.Key(AwsExpirationKey).SetValue(credentials.Expiration.UTC().Format(time.RFC3339))
+	} else {
+		section.DeleteKey(AwsExpirationKey)
+	}
+	section.Key(AwsProfileKey).SetValue(profileName)
 }
 
 func writeTo(dest io.Writer, cfg *ini.File) error {
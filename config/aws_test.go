@@ -0,0 +1,54 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestUpdateCredentialsExpiration(t *testing.T) {
+	previous := "[dev]\n" +
+		"aws_access_key_id = ASIAOLD\n" +
+		"aws_secret_access_key = secret-old\n" +
+		"aws_session_token = token-old\n" +
+		AwsExpirationKey + " = 2020-01-01T00:00:00Z\n" +
+		AwsProfileKey + " = dev\n"
+
+	withExpiration := testCredentials("ASIANEW")
+	withoutExpiration := testCredentials("ASIANEW")
+	withoutExpiration.Expiration = nil
+
+	tests := []struct {
+		name           string
+		section        AwsCredentialsSection
+		wantExpiration string
+	}{
+		{"expiration is updated", AwsCredentialsSection{Name: "dev", ProfileName: "dev", Credentials: withExpiration}, "2030-01-01T00:00:00Z"},
+		{"stale expiration is removed", AwsCredentialsSection{Name: "dev", ProfileName: "dev", Credentials: withoutExpiration}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if _, err := updateCredentials([]AwsCredentialsSection{test.section}, false, strings.NewReader(previous), &out); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := ini.Load(out.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			section := cfg.Section("dev")
+			if got := section.Key("aws_access_key_id").String(); got != "ASIANEW" {
+				t.Errorf("aws_access_key_id = %q, want %q", got, "ASIANEW")
+			}
+			if test.wantExpiration == "" && section.HasKey(AwsExpirationKey) {
+				t.Errorf("%s = %q, want the key removed", AwsExpirationKey, section.Key(AwsExpirationKey).String())
+			} else if got := section.Key(AwsExpirationKey).String(); got != test.wantExpiration {
+				t.Errorf("%s = %q, want %q", AwsExpirationKey, got, test.wantExpiration)
+			}
+		})
+	}
+}
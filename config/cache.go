@@ -0,0 +1,466 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+)
+
+// GetCredentialCacheDir returns the folder holding one cached credentials file per profile
+func GetCredentialCacheDir() (string, error) {
+	configPath, err := GetMaroonConfigFile()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(configPath), "cache"), nil
+}
+
+// getCredentialCacheFile returns the path of the cached credentials file for a profile
+func getCredentialCacheFile(profileName string) (string, error) {
+	cacheDir, err := GetCredentialCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get credential cache path")
+	}
+
+	return filepath.Join(cacheDir, profileName+".json"), nil
+}
+
+// unreadableEntryError is returned for a cache entry that exists but cannot be decrypted or parsed
+type unreadableEntryError struct {
+	err error
+}
+
+func (e *unreadableEntryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unreadableEntryError) Unwrap() error {
+	return e.err
+}
+
+// unreadableWarned holds the profiles whose unreadable cache entry was already reported by this process
+var unreadableWarned sync.Map
+
+// ReadCachedCredentials returns the cached credentials for a profile, or empty credentials if none are cached. An
+// entry that cannot be decrypted, i.e. because MAROON_CACHE_PASSPHRASE is not set or the key file is missing, is
+// treated as missing with a warning on stderr, so the credentials are fetched again and the entry is replaced.
+func ReadCachedCredentials(profileName string) (types.Credentials, error) {
+	credentials, err := readCachedCredentials(profileName)
+
+	var unreadable *unreadableEntryError
+	if errors.As(err, &unreadable) {
+		if _, warned := unreadableWarned.LoadOrStore(profileName, true); !warned {
+			fmt.Fprintln(os.Stderr, color.YellowString("Ignoring cached credentials for profile '%s': %s", profileName, err.Error()))
+		}
+		return types.Credentials{}, nil
+	}
+
+	return credentials, err
+}
+
+// readCachedCredentials is ReadCachedCredentials without the fallback for unreadable entries
+func readCachedCredentials(profileName string) (types.Credentials, error) {
+	cacheConfig, err := GetCacheConfig()
+	if err != nil {
+		return types.Credentials{}, err
+	}
+
+	return readCacheEntry(profileName, func() (*CacheKey, error) {
+		return LoadCacheKey(cacheConfig, false)
+	})
+}
+
+// WriteCachedCredentials replaces the cached credentials for a profile. Other profiles' cache entries are untouched.
+func WriteCachedCredentials(profileName string, credentials types.Credentials) error {
+	cacheConfig, err := GetCacheConfig()
+	if err != nil {
+		return err
+	}
+
+	var key *CacheKey
+	if cacheConfig.Storage == CacheStorageEncrypted {
+		if key, err = LoadCacheKey(cacheConfig, true); err != nil {
+			return err
+		}
+	}
+
+	return writeCacheEntry(profileName, credentials, key)
+}
+
+// ListCachedProfiles returns the names of the profiles that have a credential cache entry
+func ListCachedProfiles() ([]string, error) {
+	cacheDir, err := GetCredentialCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Failed to list credential cache")
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".json") {
+			profiles = append(profiles, strings.TrimSuffix(name, ".json"))
+		}
+	}
+
+	return profiles, nil
+}
+
+// RewriteCredentialCache reads every cache entry with oldKey and writes it back in plaintext when newKey is nil,
+// or encrypted with newKey otherwise. It is used to switch storage modes and to rotate the cache passphrase. Nothing
+// is rewritten unless every entry can be read.
+func RewriteCredentialCache(oldKey *CacheKey, newKey *CacheKey) error {
+	profiles, locks, err := lockCachedProfiles()
+	if err != nil {
+		return err
+	}
+	defer unlockAll(locks)
+
+	entries, err := readCacheEntries(profiles, oldKey)
+	if err != nil {
+		return err
+	}
+
+	return rewriteCacheEntries(entries, oldKey, newKey)
+}
+
+// RotateCacheKeyFile replaces the cache key file with a new random key and re-encrypts every cache entry with it.
+// Every entry is read with the current key first, and the new key only replaces the key file once every entry has
+// been re-encrypted. The previous key is kept next to the key file with a '.old' suffix.
+func RotateCacheKeyFile(cacheConfig *CacheConfig) error {
+	keyPath, err := ExpandPath(cacheConfig.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	profiles, locks, err := lockCachedProfiles()
+	if err != nil {
+		return err
+	}
+	defer unlockAll(locks)
+
+	lock, err := lockConfig()
+	if err != nil {
+		return errors.Wrap(err, "Could not lock Maroon config")
+	}
+	defer lock.Unlock()
+
+	oldKey, err := readCacheKeyFile(keyPath)
+	if os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("Cache key file '%s' does not exist. Set %s or restore the key file", keyPath, CachePassphraseEnv))
+	} else if err != nil {
+		return err
+	}
+
+	entries, err := readCacheEntries(profiles, oldKey)
+	if err != nil {
+		return err
+	}
+
+	tmpPath, err := writeCacheKeyTemp(keyPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	newKey, err := readCacheKeyFile(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	oldKeyBytes, err := os.ReadFile(keyPath)
+	if err == nil {
+		err = writeFileAtomic(keyPath+".old", oldKeyBytes, 0600)
+	}
+	if err != nil {
+		return errors.Wrap(err, "Could not back up the current cache key")
+	}
+
+	if err = rewriteCacheEntries(entries, oldKey, newKey); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmpPath, keyPath); err != nil {
+		err = errors.Wrap(err, "Could not replace cache key file")
+		if rollbackErr := writeCacheEntries(entries, oldKey); rollbackErr != nil {
+			return errors.Wrap(err, fmt.Sprintf("cached credentials could not be restored to the previous key (%v), they are encrypted with the key in '%s'", rollbackErr, tmpPath))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// lockCachedProfiles locks every profile with a cache entry. Locks are taken in name order so that concurrent callers
+// cannot deadlock.
+func lockCachedProfiles() ([]string, []*FileLock, error) {
+	profiles, err := ListCachedProfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(profiles)
+
+	var locks []*FileLock
+	for _, profileName := range profiles {
+		lock, err := LockProfile(profileName)
+		if err != nil {
+			unlockAll(locks)
+			return nil, nil, err
+		}
+		locks = append(locks, lock)
+	}
+
+	return profiles, locks, nil
+}
+
+func unlockAll(locks []*FileLock) {
+	for _, lock := range locks {
+		lock.Unlock()
+	}
+}
+
+// readCacheEntries reads the cache entries of profiles with key
+func readCacheEntries(profiles []string, key *CacheKey) (map[string]types.Credentials, error) {
+	entries := map[string]types.Credentials{}
+	for _, profileName := range profiles {
+		entry, err := readCacheEntry(profileName, func() (*CacheKey, error) {
+			if key == nil {
+				return nil, errors.New("Cache entry is encrypted but no key was given")
+			}
+			return key, nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Could not read cached credentials for profile '%s'", profileName))
+		}
+		entries[profileName] = entry
+	}
+
+	return entries, nil
+}
+
+// rewriteCacheEntries writes entries with newKey. If any cannot be written, every entry is written back with oldKey.
+func rewriteCacheEntries(entries map[string]types.Credentials, oldKey *CacheKey, newKey *CacheKey) error {
+	err := writeCacheEntries(entries, newKey)
+	if err == nil {
+		return nil
+	}
+
+	if rollbackErr := writeCacheEntries(entries, oldKey); rollbackErr != nil {
+		return errors.Wrap(err, fmt.Sprintf("cached credentials could not be restored either (%v)", rollbackErr))
+	}
+	return err
+}
+
+// writeCacheEntries writes entries, encrypted with key unless key is nil
+func writeCacheEntries(entries map[string]types.Credentials, key *CacheKey) error {
+	for profileName, entry := range entries {
+		if err := writeCacheEntry(profileName, entry, key); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("Could not rewrite cached credentials for profile '%s'", profileName))
+		}
+	}
+
+	return nil
+}
+
+// readCacheEntry reads a plaintext or encrypted cache entry. loadKey is only called for encrypted entries.
+func readCacheEntry(profileName string, loadKey func() (*CacheKey, error)) (types.Credentials, error) {
+	var credentials types.Credentials
+
+	cachePath, err := getCredentialCacheFile(profileName)
+	if err != nil {
+		return credentials, err
+	}
+
+	credentialsBytes, err := os.ReadFile(cachePath)
+	if os.IsNotExist(err) || err == nil && len(credentialsBytes) == 0 {
+		return credentials, nil
+	} else if err != nil {
+		return credentials, errors.Wrap(err, "Failed to read cached credentials")
+	}
+
+	if isEncryptedEntry(credentialsBytes) {
+		key, err := loadKey()
+		if err != nil {
+			return credentials, &unreadableEntryError{err}
+		}
+		if credentialsBytes, err = decryptEntry(key, profileName, credentialsBytes); err != nil {
+			return credentials, &unreadableEntryError{err}
+		}
+	}
+
+	if err = json.Unmarshal(credentialsBytes, &credentials); err != nil {
+		return credentials, &unreadableEntryError{errors.Wrap(err, "Failed to unmarshal cached credentials")}
+	}
+
+	return credentials, nil
+}
+
+// writeCacheEntry writes a cache entry, encrypted with key unless key is nil
+func writeCacheEntry(profileName string, credentials types.Credentials, key *CacheKey) error {
+	cachePath, err := getCredentialCacheFile(profileName)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
+		return errors.Wrap(err, "Cannot create credential cache")
+	}
+
+	credentialsBytes, err := json.MarshalIndent(credentials, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal credentials")
+	}
+
+	if key != nil {
+		if credentialsBytes, err = encryptEntry(key, profileName, credentialsBytes); err != nil {
+			return errors.Wrap(err, "Failed to encrypt credentials")
+		}
+	}
+
+	// Write to a temporary file and rename it over the entry, so processes reading the cache without holding the
+	// profile lock never see a partially written entry
+	file, err := os.CreateTemp(filepath.Dir(cachePath), profileName+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "Failed to open credential cache")
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(credentialsBytes); err != nil {
+		file.Close()
+		return errors.Wrap(err, "Failed to write credential cache")
+	}
+	if err = file.Close(); err != nil {
+		return errors.Wrap(err, "Failed to write credential cache")
+	}
+
+	if err = os.Rename(file.Name(), cachePath); err != nil {
+		return errors.Wrap(err, "Failed to replace credential cache")
+	}
+
+	return nil
+}
+
+// RemoveCachedCredentials deletes the cached credentials for a profile, if any
+func RemoveCachedCredentials(profileName string) error {
+	cachePath, err := getCredentialCacheFile(profileName)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(cachePath); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Failed to remove cached credentials")
+	}
+
+	return nil
+}
+
+// ClearCachedCredentials deletes the cached credentials for a profile while holding the profile's lock, so a
+// concurrent refresh cannot write them back. With expiredOnly, unexpired credentials are kept. It returns the
+// removed credentials and whether there were any.
+func ClearCachedCredentials(profileName string, expiredOnly bool) (types.Credentials, bool, error) {
+	lock, err := LockProfile(profileName)
+	if err != nil {
+		return types.Credentials{}, false, err
+	}
+	defer lock.Unlock()
+
+	cachePath, err := getCredentialCacheFile(profileName)
+	if err != nil {
+		return types.Credentials{}, false, err
+	}
+	if _, err = os.Stat(cachePath); os.IsNotExist(err) {
+		return types.Credentials{}, false, nil
+	}
+
+	// An entry that cannot be read, i.e. because the cache key is gone, is still removed unless only expired
+	// entries are pruned
+	credentials, err := readCachedCredentials(profileName)
+	if err != nil && expiredOnly {
+		return types.Credentials{}, false, errors.Wrap(err, fmt.Sprintf("Could not read cached credentials for profile '%s'", profileName))
+	}
+	if expiredOnly && credentials.Expiration != nil && credentials.Expiration.After(time.Now()) {
+		return types.Credentials{}, false, nil
+	}
+
+	if err = RemoveCachedCredentials(profileName); err != nil {
+		return types.Credentials{}, false, err
+	}
+
+	return credentials, true, nil
+}
+
+// legacyConfig is the shape of configs written before credentials moved to the credential cache
+type legacyConfig struct {
+	Profiles map[string]struct {
+		Credentials types.Credentials `json:"credentials"`
+	}
+}
+
+// migrateLegacyCredentials moves credentials stored inside config.json into the credential cache. It reports whether
+// any were found, in which case the config should be rewritten without them.
+func migrateLegacyCredentials(config *Config, configBytes []byte) (bool, error) {
+	legacy, err := legacyCredentials(configBytes)
+	if err != nil {
+		return false, err
+	}
+
+	var key *CacheKey
+	migrated := false
+	for profileName, credentials := range legacy {
+		// The cache settings come from the config being read, reading it again here would recurse
+		if key == nil && !migrated {
+			cacheConfig, err := cacheConfigWithDefaults(config.Cache)
+			if err != nil {
+				return false, err
+			}
+			if cacheConfig.Storage == CacheStorageEncrypted {
+				if key, err = loadCacheKey(cacheConfig, true, true); err != nil {
+					return false, err
+				}
+			}
+		}
+
+		if err := writeCacheEntry(profileName, credentials, key); err != nil {
+			return false, errors.Wrap(err, "Failed to migrate credentials to the credential cache")
+		}
+		migrated = true
+	}
+
+	return migrated, nil
+}
+
+// legacyCredentials returns the credentials held for each profile by a config written by an older version
+func legacyCredentials(configBytes []byte) (map[string]types.Credentials, error) {
+	if len(configBytes) == 0 {
+		return nil, nil
+	}
+
+	var legacy legacyConfig
+	if err := json.Unmarshal(configBytes, &legacy); err != nil {
+		return nil, errors.Wrap(err, "Failed to unmarshal Maroon config")
+	}
+
+	credentials := map[string]types.Credentials{}
+	for profileName, profile := range legacy.Profiles {
+		if profile.Credentials != (types.Credentials{}) {
+			credentials[profileName] = profile.Credentials
+		}
+	}
+
+	return credentials, nil
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRewriteCredentialCache(t *testing.T) {
+	keyFileKey := &CacheKey{Key: bytes.Repeat([]byte{1}, 32)}
+	otherKeyFileKey := &CacheKey{Key: bytes.Repeat([]byte{2}, 32)}
+	passphraseKey := &CacheKey{Passphrase: "passphrase"}
+
+	tests := []struct {
+		name   string
+		oldKey *CacheKey
+		newKey *CacheKey
+	}{
+		{"plaintext to encrypted", nil, keyFileKey},
+		{"encrypted to plaintext", keyFileKey, nil},
+		{"rotate key file key", keyFileKey, otherKeyFileKey},
+		{"key file to passphrase", keyFileKey, passphraseKey},
+		{"rotate passphrase", passphraseKey, &CacheKey{Passphrase: "new passphrase"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetHome(t)
+			want := map[string]string{"dev": "ASIADEV", "prod": "ASIAPROD"}
+			for profileName, accessKeyId := range want {
+				if err := writeCacheEntry(profileName, testCredentials(accessKeyId), test.oldKey); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := RewriteCredentialCache(test.oldKey, test.newKey); err != nil {
+				t.Fatal(err)
+			}
+
+			for profileName, accessKeyId := range want {
+				contents, err := os.ReadFile(cacheFilePath(t, profileName))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if isEncryptedEntry(contents) != (test.newKey != nil) {
+					t.Fatalf("entry of profile '%s' encrypted: %v, want %v", profileName, isEncryptedEntry(contents), test.newKey != nil)
+				}
+
+				got, err := readCacheEntry(profileName, func() (*CacheKey, error) { return test.newKey, nil })
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !sameCredentials(got, testCredentials(accessKeyId)) {
+					t.Fatalf("profile '%s' has credentials %+v after the rewrite", profileName, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteCredentialCacheKeepsEntriesWhenOneIsUnreadable(t *testing.T) {
+	resetHome(t)
+	oldKey := &CacheKey{Key: bytes.Repeat([]byte{1}, 32)}
+	if err := writeCacheEntry("dev", testCredentials("ASIADEV"), oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCacheEntry("prod", testCredentials("ASIAPROD"), &CacheKey{Key: bytes.Repeat([]byte{3}, 32)}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(cacheFilePath(t, "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = RewriteCredentialCache(oldKey, &CacheKey{Key: bytes.Repeat([]byte{2}, 32)}); err == nil {
+		t.Fatal("rewrite succeeded with an entry that cannot be decrypted")
+	}
+
+	after, err := os.ReadFile(cacheFilePath(t, "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatal("a readable entry was rewritten although the rewrite failed")
+	}
+}
+
+func TestRotateCacheKeyFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		corruptProd bool
+		wantRotated bool
+	}{
+		{"every entry readable", false, true},
+		{"unreadable entry", true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetHome(t)
+			writeTestConfig(t, `{"Cache":{"storage":"encrypted"}}`)
+			cacheConfig, err := GetCacheConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			oldKey, err := LoadCacheKey(cacheConfig, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			oldKeyBytes, _ := os.ReadFile(cacheConfig.KeyFile)
+
+			if err = writeCacheEntry("dev", testCredentials("ASIADEV"), oldKey); err != nil {
+				t.Fatal(err)
+			}
+			if test.corruptProd {
+				if err = os.WriteFile(cacheFilePath(t, "prod"), []byte(`{"version":1,"kdf":"keyfile","nonce":"AAAAAAAAAAAAAAAA","ciphertext":"AAAA"}`), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = RotateCacheKeyFile(cacheConfig)
+			if (err == nil) != test.wantRotated {
+				t.Fatalf("got error %v, want rotated %v", err, test.wantRotated)
+			}
+
+			newKey, err := LoadCacheKey(cacheConfig, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(newKey.Key, oldKey.Key) == test.wantRotated {
+				t.Fatalf("key changed: %v, want %v", !bytes.Equal(newKey.Key, oldKey.Key), test.wantRotated)
+			}
+
+			got, err := readCacheEntry("dev", func() (*CacheKey, error) { return newKey, nil })
+			if err != nil {
+				t.Fatalf("entry cannot be read with the current key file: %v", err)
+			}
+			if !sameCredentials(got, testCredentials("ASIADEV")) {
+				t.Fatalf("got credentials %+v", got)
+			}
+
+			if test.wantRotated {
+				backup, err := os.ReadFile(cacheConfig.KeyFile + ".old")
+				if err != nil || !bytes.Equal(backup, oldKeyBytes) {
+					t.Fatalf("previous key was not kept: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadCachedCredentialsIgnoresUnreadableEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"wrong key", `{"version":1,"kdf":"keyfile","nonce":"AAAAAAAAAAAAAAAA","ciphertext":"AAAA"}`},
+		{"missing passphrase", `{"version":1,"kdf":"scrypt","salt":"AAAA","nonce":"AAAAAAAAAAAAAAAA","ciphertext":"AAAA"}`},
+		{"invalid json", `{"AccessKeyId":`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetHome(t)
+			writeTestConfig(t, `{"Cache":{"storage":"encrypted"}}`)
+			cacheConfig, _ := GetCacheConfig()
+			if _, err := LoadCacheKey(cacheConfig, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(cacheDirPath(t), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(cacheFilePath(t, "dev"), []byte(test.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			credentials, err := ReadCachedCredentials("dev")
+			if err != nil {
+				t.Fatalf("got error %v, want a cache miss", err)
+			}
+			if credentials.AccessKeyId != nil {
+				t.Fatalf("got credentials %+v, want none", credentials)
+			}
+
+			if _, err = readCachedCredentials("dev"); err == nil {
+				t.Fatal("the unreadable entry was not reported")
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		cache     string
+		encrypted bool
+	}{
+		{"plaintext cache", ``, false},
+		{"encrypted cache", `"Cache":{"storage":"encrypted"},`, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetHome(t)
+			writeTestConfig(t, `{`+test.cache+`"Profiles":{
+				"dev":{"accountId":"123456789012","roleToAssume":"r","region":"us-east-1","credentials":{"AccessKeyId":"ASIADEV","SecretAccessKey":"secret-ASIADEV","SessionToken":"token-ASIADEV","Expiration":"2030-01-01T00:00:00Z"}},
+				"prod":{"accountId":"123456789012","roleToAssume":"r","region":"us-east-1"}}}`)
+
+			profile, err := GetProfile("dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameCredentials(profile.Credentials, testCredentials("ASIADEV")) {
+				t.Fatalf("got credentials %+v", profile.Credentials)
+			}
+
+			configPath, _ := GetMaroonConfigFile()
+			configBytes, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(configBytes), "ASIADEV") {
+				t.Fatal("the config still holds the migrated credentials")
+			}
+
+			contents, err := os.ReadFile(cacheFilePath(t, "dev"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isEncryptedEntry(contents) != test.encrypted {
+				t.Fatalf("migrated entry encrypted: %v, want %v", isEncryptedEntry(contents), test.encrypted)
+			}
+			if _, err = os.Stat(cacheFilePath(t, "prod")); !os.IsNotExist(err) {
+				t.Fatal("a cache entry was written for a profile without credentials")
+			}
+		})
+	}
+}
+
+func cacheDirPath(t *testing.T) string {
+	t.Helper()
+
+	cacheDir, err := GetCredentialCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cacheDir
+}
+
+func cacheFilePath(t *testing.T, profileName string) string {
+	t.Helper()
+
+	cachePath, err := getCredentialCacheFile(profileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cachePath
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/sts/types"
@@ -20,16 +22,68 @@ type Credentials struct {
 }
 
 type Profile struct {
-	AccountId    string            `json:"accountId" binding:"required,numeric,len=12"`
-	RoleToAssume string            `json:"roleToAssume" binding:"required"`
-	Region       string            `json:"region" binding:"required"`
-	Credentials  types.Credentials `json:"credentials,omitempty"`
+	AccountId    string `json:"accountId" binding:"required,numeric,len=12"`
+	RoleToAssume string `json:"roleToAssume" binding:"required"`
+	Region       string `json:"region" binding:"required"`
+	Context      string `json:"context,omitempty"`
+	// Production profiles ask for confirmation before destructive operations such as revoking sessions
+	Production bool `json:"production,omitempty"`
+	// SessionDuration and RefreshBefore override the Session config for this profile, i.e. 12h and 30m
+	SessionDuration string `json:"sessionDuration,omitempty"`
+	RefreshBefore   string `json:"refreshBefore,omitempty"`
+	// MaxSessionDuration is the longest session the role allowed when a longer one was requested, see SetMaxSessionDuration
+	MaxSessionDuration string `json:"maxSessionDuration,omitempty"`
+	// Credentials are kept in the credential cache rather than the config file, see GetProfile
+	Credentials types.Credentials `json:"-"`
 }
 
+// RetryConfig controls how failed Maroon API calls are retried. Deadline is a Go duration string (i.e. 90s).
+type RetryConfig struct {
+	MaxAttempts int    `json:"maxAttempts,omitempty"`
+	Deadline    string `json:"deadline,omitempty"`
+}
+
+// NetworkConfig holds proxy and TLS settings applied to every request Maroon makes. Paths may start with '~'.
+type NetworkConfig struct {
+	Proxy      string `json:"proxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+	CABundle   string `json:"caBundle,omitempty"`
+	ClientCert string `json:"clientCert,omitempty"`
+	ClientKey  string `json:"clientKey,omitempty"`
+}
+
+// TokenConfig controls where the token used to authenticate to the Maroon API comes from. Sources is the order in
+// which the implicit sources 'env', 'file', 'command' and 'spark' are tried. ExpiryWarning is a Go duration string
+// (i.e. 30m); a warning is printed when the token expires within it. RefreshCommand is run to refresh an expired or
+// rejected token, such as 'spark auth'.
+type TokenConfig struct {
+	Sources        []string `json:"sources,omitempty"`
+	File           string   `json:"file,omitempty"`
+	Command        string   `json:"command,omitempty"`
+	ExpiryWarning  string   `json:"expiryWarning,omitempty"`
+	RefreshCommand string   `json:"refreshCommand,omitempty"`
+}
+
+// DefaultTokenSources is the order implicit token sources are tried in when none is configured
+var DefaultTokenSources = []string{"env", "file", "command", "spark"}
+
 type Config struct {
-	Profiles map[string]Profile `json:",omitempty"`
+	Endpoint       string                `json:",omitempty"`
+	ApiPathPrefix  string                `json:",omitempty"`
+	Retry          *RetryConfig          `json:",omitempty"`
+	Network        *NetworkConfig        `json:",omitempty"`
+	Token          *TokenConfig          `json:",omitempty"`
+	Cache          *CacheConfig          `json:",omitempty"`
+	Session        *SessionConfig        `json:",omitempty"`
+	CurrentContext string                `json:",omitempty"`
+	Contexts       map[string]ApiContext `json:",omitempty"`
+	Profiles       map[string]Profile    `json:",omitempty"`
 }
 
+// DefaultApiPathPrefix is the path prefix used by the Maroon API when none is configured. An API served at the root
+// of its endpoint is configured with the prefix '/'.
+const DefaultApiPathPrefix = "/api/v1"
+
 func GetMaroonConfigFile() (string, error) {
 	home, err := homedir.Dir()
 	if err != nil {
@@ -55,54 +109,113 @@ func OpenReadConfigFile() (*os.File, error) {
 	return os.OpenFile(configPath, os.O_RDONLY|os.O_CREATE, 0600)
 }
 
-// OpenWriteConfigFile opens the config file with write only permissions
-func OpenWriteConfigFile() (*os.File, error) {
-	configPath, err := GetMaroonConfigFile()
+// writeMaroonConfig atomically replaces the maroon config file with an updated config, keeping a backup of the
+// previous version. Callers must hold the config lock.
+func writeMaroonConfig(config *Config) error {
+	configFile, err := GetManagedFile(ManagedConfig)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to get config path")
+		return errors.Wrap(err, "unable to get config path")
 	}
 
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		if err = os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
-			return nil, errors.Wrap(err, "Cannot create config file")
-		}
+	bytes, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to marshal the new maroon config")
 	}
 
-	return os.OpenFile(configPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err = writeManagedFile(configFile, bytes); err != nil {
+		return errors.Wrap(err, "writeMaroonConfig failed to write to the maroon config file")
+	}
+
+	return nil
 }
 
-// writeMaroonConfig overwrites the maroon config file with an updated config
-func writeMaroonConfig(config *Config) error {
-	file, err := OpenWriteConfigFile()
+// readMaroonConfig reads the maroon config and returns a struct containing the data from the file. The config is
+// locked while it is read so a concurrent update is never seen half written.
+func readMaroonConfig() (*Config, error) {
+	lock, err := rLockConfig()
 	if err != nil {
-		return errors.Wrap(err, "writeMaroonConfig failed to open the maroon config file")
+		return nil, errors.Wrap(err, "Could not lock Maroon config")
 	}
-	defer file.Close()
 
-	bytes, err := json.MarshalIndent(config, "", "  ")
+	config, configBytes, err := parseMaroonConfig()
+	lock.Unlock()
 	if err != nil {
-		return errors.Wrap(err, "Failed to marshal the new maroon config")
+		return nil, err
+	}
+
+	// Configs written by older versions hold credentials. Moving them rewrites the config, which needs the exclusive
+	// lock, so it is taken once the shared one is released.
+	if legacy, err := legacyCredentials(configBytes); err != nil {
+		return nil, err
+	} else if len(legacy) > 0 {
+		err = updateMaroonConfig(func(migrated *Config) error {
+			config = migrated
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not migrate credentials from Maroon config")
+		}
 	}
 
-	_, err = file.Write(bytes)
+	return config, nil
+}
+
+// updateMaroonConfig reads the maroon config, applies update to it and writes it back while holding the config lock,
+// so updates made by concurrent Maroon processes are not lost
+func updateMaroonConfig(update func(config *Config) error) error {
+	lock, err := lockConfig()
 	if err != nil {
-		return errors.Wrap(err, "writeMaroonConfig failed to write to the maroon config file")
+		return errors.Wrap(err, "Could not lock Maroon config")
+	}
+	defer lock.Unlock()
+
+	config, err := loadMaroonConfig()
+	if err != nil {
+		return errors.Wrap(err, "Could not read Maroon config")
+	}
+
+	if err = update(config); err != nil {
+		return err
+	}
+
+	if err = writeMaroonConfig(config); err != nil {
+		return errors.Wrap(err, "Could not write to Maroon config")
 	}
 
 	return nil
 }
 
-// readMaroonConfig reads the maroon config and returns a struct containing the data from the file
-func readMaroonConfig() (*Config, error) {
+// loadMaroonConfig reads the maroon config and moves credentials held by configs written by older versions to the
+// credential cache. Callers must hold the exclusive config lock.
+func loadMaroonConfig() (*Config, error) {
+	config, configBytes, err := parseMaroonConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if migrated, err := migrateLegacyCredentials(config, configBytes); err != nil {
+		return nil, err
+	} else if migrated {
+		if err = writeMaroonConfig(config); err != nil {
+			return nil, errors.Wrap(err, "Could not remove migrated credentials from Maroon config")
+		}
+	}
+
+	return config, nil
+}
+
+// parseMaroonConfig reads the maroon config and returns it along with its raw bytes. Callers must hold the config
+// lock.
+func parseMaroonConfig() (*Config, []byte, error) {
 	file, err := OpenReadConfigFile()
 	if err != nil {
-		return nil, errors.Wrap(err, "readMaroonConfig failed to open the Maroon config file")
+		return nil, nil, errors.Wrap(err, "readMaroonConfig failed to open the Maroon config file")
 	}
 	defer file.Close()
 
 	stat, err := file.Stat()
 	if err != nil {
-		return nil, errors.Wrap(err, "readMaroonConfig unable to retrieve info about Maroon config file")
+		return nil, nil, errors.Wrap(err, "readMaroonConfig unable to retrieve info about Maroon config file")
 	}
 
 	configBytes := make([]byte, stat.Size())
@@ -110,39 +223,47 @@ func readMaroonConfig() (*Config, error) {
 
 	count, err := file.Read(configBytes)
 	if err != nil || count < 0 {
-		return nil, errors.Wrap(err, "readMaroonConfig failed to read the Maroon config file")
+		return nil, nil, errors.Wrap(err, "readMaroonConfig failed to read the Maroon config file")
 	} else if count == 0 {
-		return &config, nil
+		return &config, nil, nil
 	}
 
 	err = json.Unmarshal(configBytes, &config)
 	if err != nil {
-		return nil, errors.Wrap(err, "Failed to unmarshal Maroon config")
+		return nil, nil, errors.Wrap(err, "Failed to unmarshal Maroon config")
 	}
 
-	return &config, nil
+	return &config, configBytes, nil
 }
 
 // UpdateCognitoConfig takes a as an argument and adds it to the maroon config file
 func AddProfile(profileName string, profile Profile) error {
-	config, err := readMaroonConfig()
-	if err != nil {
-		return errors.Wrap(err, "Could not read Maroon config")
+	if err := validateProfileSession(profile); err != nil {
+		return err
 	}
 
-	if profileExists(profileName, *config) {
-		return errors.New(fmt.Sprintf("Profile '%s' already exists", profileName))
-	}
+	err := updateMaroonConfig(func(config *Config) error {
+		if profileExists(profileName, *config) {
+			return errors.New(fmt.Sprintf("Profile '%s' already exists", profileName))
+		}
 
-	if config.Profiles == nil {
-		config.Profiles = map[string]Profile{}
-	}
+		if err := validateProfileRefreshBefore(profile, config.Session); err != nil {
+			return err
+		}
+
+		if _, ok := config.Contexts[profile.Context]; profile.Context != "" && !ok {
+			return errors.New(fmt.Sprintf("Context '%s' does not exist", profile.Context))
+		}
 
-	config.Profiles[profileName] = profile
+		if config.Profiles == nil {
+			config.Profiles = map[string]Profile{}
+		}
 
-	err = writeMaroonConfig(config)
+		config.Profiles[profileName] = profile
+		return nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "Could not write to Maroon config")
+		return err
 	}
 
 	err = AddCredentialProcess(profileName, profile.Region)
@@ -154,24 +275,19 @@ func AddProfile(profileName string, profile Profile) error {
 }
 
 func RemoveProfile(profileName string) error {
-	config, err := readMaroonConfig()
-	if err != nil {
-		return errors.Wrap(err, "Could not read Maroon config")
-	}
+	err := updateMaroonConfig(func(config *Config) error {
+		if !profileExists(profileName, *config) {
+			return errors.New("Profile does not exist")
+		}
 
-	if config.Profiles == nil {
+		delete(config.Profiles, profileName)
 		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if !profileExists(profileName, *config) {
-		return errors.New("Profile does not exist")
-	}
-
-	delete(config.Profiles, profileName)
-
-	writeMaroonConfig(config)
-
-	return nil
+	return RemoveCachedCredentials(profileName)
 }
 
 func GetProfile(profileName string) (*Profile, error) {
@@ -186,9 +302,14 @@ func GetProfile(profileName string) (*Profile, error) {
 
 	profile := config.Profiles[profileName]
 
+	if profile.Credentials, err = ReadCachedCredentials(profileName); err != nil {
+		return nil, errors.Wrap(err, "Could not read cached credentials")
+	}
+
 	return &profile, nil
 }
 
+// UpdateCredentials replaces the cached credentials of a profile without touching the Maroon config or other profiles
 func UpdateCredentials(profileName string, credentials types.Credentials) error {
 	config, err := readMaroonConfig()
 	if err != nil {
@@ -199,17 +320,80 @@ func UpdateCredentials(profileName string, credentials types.Credentials) error
 		return errors.New("Profile does not exist")
 	}
 
-	profile := config.Profiles[profileName]
+	if err = WriteCachedCredentials(profileName, credentials); err != nil {
+		return errors.Wrap(err, "Could not update credential cache")
+	}
+
+	return nil
+}
 
-	profile.Credentials = credentials
+// GetConfig returns the full Maroon config
+func GetConfig() (*Config, error) {
+	config, err := readMaroonConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read Maroon config")
+	}
 
-	config.Profiles[profileName] = profile
+	return config, nil
+}
 
-	if err = writeMaroonConfig(config); err != nil {
-		return errors.Wrap(err, "Could not update Maroon config")
+// MaroonIsInitialized returns an error if no Maroon API endpoint has been configured
+func MaroonIsInitialized() (*Config, error) {
+	config, err := GetConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if config.Endpoint == "" {
+		return nil, errors.New("Maroon has not been initialized. Please run 'maroon init' to initialize Maroon.")
+	}
+
+	return config, nil
+}
+
+// UpdateEndpoint stores the Maroon API base URL and path prefix in the Maroon config
+func UpdateEndpoint(endpoint string, apiPathPrefix string) error {
+	return updateMaroonConfig(func(config *Config) error {
+		config.Endpoint = endpoint
+		config.ApiPathPrefix = apiPathPrefix
+		return nil
+	})
+}
+
+// GetApiUrl returns the base URL that Maroon API operations are appended to for the given context. If override
+// is not empty, it is used in place of the context's endpoint.
+func GetApiUrl(apiContext *ApiContext, override string) (string, error) {
+	endpoint := apiContext.Endpoint
+	if override != "" {
+		endpoint = override
+	}
+
+	if endpoint == "" {
+		return "", errors.New("Maroon has not been initialized. Please run 'maroon init' or pass '--endpoint' to set the Maroon API endpoint.")
+	}
+
+	return JoinApiUrl(endpoint, apiContext.ApiPathPrefix)
+}
+
+// JoinApiUrl validates the endpoint and appends the API path prefix to it, or DefaultApiPathPrefix when it is empty
+func JoinApiUrl(endpoint string, apiPathPrefix string) (string, error) {
+	if apiPathPrefix == "" {
+		apiPathPrefix = DefaultApiPathPrefix
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("Invalid Maroon API endpoint '%s'", endpoint))
+	} else if parsed.Scheme != "http" && parsed.Scheme != "https" || parsed.Host == "" {
+		return "", errors.New(fmt.Sprintf("Invalid Maroon API endpoint '%s'. Endpoint must be an http(s) URL", endpoint))
+	}
+
+	apiUrl := strings.TrimRight(endpoint, "/")
+	if prefix := strings.Trim(apiPathPrefix, "/"); prefix != "" {
+		apiUrl = apiUrl + "/" + prefix
+	}
+
+	return apiUrl, nil
 }
 
 func profileExists(profileName string, config Config) bool {
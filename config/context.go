@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ApiContext is a named Maroon API deployment along with the identity used to authenticate to it
+type ApiContext struct {
+	Endpoint      string `json:"endpoint"`
+	ApiPathPrefix string `json:"apiPathPrefix,omitempty"`
+	// IdentitySource is the path to the Spark config holding the Cognito ID token for this deployment.
+	// When empty, the default Spark config is used.
+	IdentitySource string `json:"identitySource,omitempty"`
+}
+
+// AddContext adds a named API context to the Maroon config
+func AddContext(contextName string, apiContext ApiContext) error {
+	return updateMaroonConfig(func(config *Config) error {
+		if _, ok := config.Contexts[contextName]; ok {
+			return errors.New(fmt.Sprintf("Context '%s' already exists", contextName))
+		}
+
+		if config.Contexts == nil {
+			config.Contexts = map[string]ApiContext{}
+		}
+
+		config.Contexts[contextName] = apiContext
+		return nil
+	})
+}
+
+// RemoveContext removes a named API context. Contexts still used by a profile cannot be removed.
+func RemoveContext(contextName string) error {
+	return updateMaroonConfig(func(config *Config) error {
+		if _, ok := config.Contexts[contextName]; !ok {
+			return errors.New("Context does not exist")
+		}
+
+		var profiles []string
+		for profileName, profile := range config.Profiles {
+			if profile.Context == contextName {
+				profiles = append(profiles, profileName)
+			}
+		}
+		if len(profiles) > 0 {
+			sort.Strings(profiles)
+			return errors.New(fmt.Sprintf("Context '%s' is used by profiles %v", contextName, profiles))
+		}
+
+		delete(config.Contexts, contextName)
+		if config.CurrentContext == contextName {
+			config.CurrentContext = ""
+		}
+		return nil
+	})
+}
+
+// UseContext makes the named API context the one used when a profile or command does not specify one
+func UseContext(contextName string) error {
+	return updateMaroonConfig(func(config *Config) error {
+		if _, ok := config.Contexts[contextName]; !ok {
+			return errors.New("Context does not exist")
+		}
+
+		config.CurrentContext = contextName
+		return nil
+	})
+}
+
+// ResolveContext returns the named API context. If contextName is empty, the current context is used, and if no
+// context is current, the endpoint configured by 'maroon init' is returned as an unnamed context.
+func ResolveContext(contextName string) (*ApiContext, error) {
+	config, err := readMaroonConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read Maroon config")
+	}
+
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	if contextName == "" {
+		return &ApiContext{
+			Endpoint:      config.Endpoint,
+			ApiPathPrefix: config.ApiPathPrefix,
+		}, nil
+	}
+
+	apiContext, ok := config.Contexts[contextName]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Context '%s' does not exist", contextName))
+	}
+
+	return &apiContext, nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddProfileContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		context   string
+		wantError string
+	}{
+		{"no context", "", ""},
+		{"existing context", "staging", ""},
+		{"missing context", "prod", "Context 'prod' does not exist"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetHome(t)
+			writeTestConfig(t, `{"Contexts":{"staging":{"endpoint":"https://api.maroon-staging.example.com"}}}`)
+
+			err := AddProfile("dev", Profile{AccountId: "123456789012", RoleToAssume: "r", Region: "us-east-1", Context: test.context})
+			if test.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantError) {
+					t.Fatalf("got error %v, want %q", err, test.wantError)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			profile, err := GetProfile("dev")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.Context != test.context {
+				t.Fatalf("got context '%s', want '%s'", profile.Context, test.context)
+			}
+		})
+	}
+}
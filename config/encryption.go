@@ -0,0 +1,303 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	CacheStoragePlaintext = "plaintext"
+	CacheStorageEncrypted = "encrypted"
+
+	// CachePassphraseEnv holds the passphrase cache keys are derived from. When unset, a key file is used instead.
+	CachePassphraseEnv = "MAROON_CACHE_PASSPHRASE"
+
+	kdfKeyFile = "keyfile"
+	kdfScrypt  = "scrypt"
+)
+
+// CacheConfig controls how cached credentials are stored. Storage is either 'plaintext' (the default) or
+// 'encrypted'. KeyFile is the AES key used for encrypted storage when MAROON_CACHE_PASSPHRASE is not set.
+type CacheConfig struct {
+	Storage string `json:"storage,omitempty"`
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
+// CacheKey is the secret encrypted cache entries are sealed with
+type CacheKey struct {
+	// Passphrase is set when the key is derived per entry with scrypt, otherwise Key holds an AES-256 key
+	Passphrase string
+	Key        []byte
+}
+
+// encryptedEntry is the on-disk format of an encrypted cache entry
+type encryptedEntry struct {
+	Version    int    `json:"version"`
+	Kdf        string `json:"kdf"`
+	Salt       string `json:"salt,omitempty"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// GetCacheConfig returns the credential cache settings, filling in defaults
+func GetCacheConfig() (*CacheConfig, error) {
+	config, err := readMaroonConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read Maroon config")
+	}
+
+	return cacheConfigWithDefaults(config.Cache)
+}
+
+func cacheConfigWithDefaults(cacheConfig *CacheConfig) (*CacheConfig, error) {
+	result := CacheConfig{}
+	if cacheConfig != nil {
+		result = *cacheConfig
+	}
+
+	if result.Storage == "" {
+		result.Storage = CacheStoragePlaintext
+	} else if result.Storage != CacheStoragePlaintext && result.Storage != CacheStorageEncrypted {
+		return nil, errors.New(fmt.Sprintf("Invalid cache storage '%s'. Must be one of '%s', '%s'", result.Storage, CacheStoragePlaintext, CacheStorageEncrypted))
+	}
+
+	if result.KeyFile == "" {
+		configPath, err := GetMaroonConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		result.KeyFile = filepath.Join(filepath.Dir(configPath), "cache.key")
+	}
+
+	return &result, nil
+}
+
+// UpdateCacheConfig stores the credential cache settings
+func UpdateCacheConfig(cacheConfig CacheConfig) error {
+	return updateMaroonConfig(func(config *Config) error {
+		config.Cache = &cacheConfig
+		return nil
+	})
+}
+
+// LoadCacheKey returns the key for encrypted cache entries. The passphrase in MAROON_CACHE_PASSPHRASE is preferred,
+// otherwise the key file is read, and created with a random key when create is set and it does not exist.
+func LoadCacheKey(cacheConfig *CacheConfig, create bool) (*CacheKey, error) {
+	return loadCacheKey(cacheConfig, create, false)
+}
+
+// loadCacheKey is LoadCacheKey for callers that may already hold the config lock, which guards creating the key file
+func loadCacheKey(cacheConfig *CacheConfig, create bool, configLocked bool) (*CacheKey, error) {
+	if passphrase := os.Getenv(CachePassphraseEnv); passphrase != "" {
+		return &CacheKey{Passphrase: passphrase}, nil
+	}
+
+	keyPath, err := ExpandPath(cacheConfig.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := readCacheKeyFile(keyPath)
+	if !os.IsNotExist(err) {
+		return key, err
+	} else if !create {
+		return nil, errors.New(fmt.Sprintf("Cache key file '%s' does not exist. Set %s or restore the key file", keyPath, CachePassphraseEnv))
+	}
+
+	if !configLocked {
+		lock, err := lockConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not lock Maroon config")
+		}
+		defer lock.Unlock()
+	}
+
+	return createCacheKeyFile(keyPath)
+}
+
+// readCacheKeyFile reads a key file. A missing file is reported with the error of os.ReadFile.
+func readCacheKeyFile(keyPath string) (*CacheKey, error) {
+	encoded, err := os.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		return nil, err
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Could not read cache key file")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New(fmt.Sprintf("Cache key file '%s' does not contain a base64 encoded 256 bit key", keyPath))
+	}
+
+	return &CacheKey{Key: key}, nil
+}
+
+// createCacheKeyFile writes a random key to keyPath unless another process created it first, and returns the key
+// the file holds. Callers must hold the config lock.
+func createCacheKeyFile(keyPath string) (*CacheKey, error) {
+	if key, err := readCacheKeyFile(keyPath); !os.IsNotExist(err) {
+		return key, err
+	}
+
+	tmpPath, err := writeCacheKeyTemp(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmpPath)
+
+	if err = os.Rename(tmpPath, keyPath); err != nil {
+		return nil, errors.Wrap(err, "Could not write cache key file")
+	}
+
+	return readCacheKeyFile(keyPath)
+}
+
+// writeCacheKeyTemp writes a new random key to a temporary file next to keyPath and returns the temporary file's path
+func writeCacheKeyTemp(keyPath string) (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, "Could not generate cache key")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return "", errors.Wrap(err, "Cannot create cache key folder")
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(keyPath), "."+filepath.Base(keyPath)+".*.tmp")
+	if err != nil {
+		return "", errors.Wrap(err, "Could not write cache key file")
+	}
+
+	if _, err = file.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", errors.Wrap(err, "Could not write cache key file")
+	}
+
+	return file.Name(), nil
+}
+
+// isEncryptedEntry reports whether a cache entry was written by encryptEntry
+func isEncryptedEntry(contents []byte) bool {
+	var entry encryptedEntry
+	return json.Unmarshal(contents, &entry) == nil && entry.Ciphertext != ""
+}
+
+// encryptEntry seals plaintext with AES-GCM, binding it to the profile name so entries cannot be swapped
+func encryptEntry(key *CacheKey, profileName string, plaintext []byte) ([]byte, error) {
+	entry := encryptedEntry{Version: 1, Kdf: kdfKeyFile}
+
+	aesKey := key.Key
+	if key.Passphrase != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, errors.Wrap(err, "Could not generate salt")
+		}
+
+		var err error
+		if aesKey, err = deriveKey(key.Passphrase, salt); err != nil {
+			return nil, err
+		}
+		entry.Kdf = kdfScrypt
+		entry.Salt = base64.StdEncoding.EncodeToString(salt)
+	}
+
+	aead, err := newAead(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "Could not generate nonce")
+	}
+
+	entry.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	entry.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(profileName)))
+
+	return json.MarshalIndent(entry, "", "  ")
+}
+
+// decryptEntry opens a cache entry written by encryptEntry
+func decryptEntry(key *CacheKey, profileName string, contents []byte) ([]byte, error) {
+	var entry encryptedEntry
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return nil, errors.Wrap(err, "Failed to unmarshal encrypted cache entry")
+	}
+
+	aesKey := key.Key
+	switch entry.Kdf {
+	case kdfScrypt:
+		if key.Passphrase == "" {
+			return nil, errors.New(fmt.Sprintf("Cache entry is protected by a passphrase. Set %s", CachePassphraseEnv))
+		}
+		salt, err := base64.StdEncoding.DecodeString(entry.Salt)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid cache entry salt")
+		}
+		if aesKey, err = deriveKey(key.Passphrase, salt); err != nil {
+			return nil, err
+		}
+	case kdfKeyFile:
+		if aesKey == nil {
+			return nil, errors.New(fmt.Sprintf("Cache entry is protected by a key file. Unset %s", CachePassphraseEnv))
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown cache entry key derivation '%s'", entry.Kdf))
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(entry.Nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid cache entry nonce")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(entry.Ciphertext)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid cache entry ciphertext")
+	}
+
+	aead, err := newAead(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("Invalid cache entry nonce")
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(profileName))
+	if err != nil {
+		return nil, errors.New("Could not decrypt cache entry. The cache key or passphrase is wrong, or the entry was tampered with")
+	}
+
+	return plaintext, nil
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not derive cache key from passphrase")
+	}
+	return key, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid cache key")
+	}
+	return cipher.NewGCM(block)
+}
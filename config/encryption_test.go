@@ -0,0 +1,179 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		key  *CacheKey
+	}{
+		{"key file", &CacheKey{Key: bytes.Repeat([]byte{1}, 32)}},
+		{"passphrase", &CacheKey{Passphrase: "correct horse battery staple"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plaintext := []byte(`{"AccessKeyId":"ASIAEXAMPLE"}`)
+
+			sealed, err := encryptEntry(test.key, "dev", plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isEncryptedEntry(sealed) {
+				t.Fatal("encrypted entry is not recognized as encrypted")
+			}
+			if bytes.Contains(sealed, []byte("ASIAEXAMPLE")) {
+				t.Fatal("encrypted entry contains the plaintext")
+			}
+
+			opened, err := decryptEntry(test.key, "dev", sealed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatalf("got %q, want %q", opened, plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptRejectsWrongKey(t *testing.T) {
+	keyFileKey := &CacheKey{Key: bytes.Repeat([]byte{1}, 32)}
+	passphraseKey := &CacheKey{Passphrase: "first passphrase"}
+
+	tests := []struct {
+		name       string
+		encryptKey *CacheKey
+		decryptKey *CacheKey
+		wantError  string
+	}{
+		{"other key file", keyFileKey, &CacheKey{Key: bytes.Repeat([]byte{2}, 32)}, "Could not decrypt cache entry"},
+		{"other passphrase", passphraseKey, &CacheKey{Passphrase: "second passphrase"}, "Could not decrypt cache entry"},
+		{"key file for passphrase entry", passphraseKey, keyFileKey, "protected by a passphrase"},
+		{"passphrase for key file entry", keyFileKey, passphraseKey, "protected by a key file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sealed, err := encryptEntry(test.encryptKey, "dev", []byte("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = decryptEntry(test.decryptKey, "dev", sealed)
+			if err == nil || !strings.Contains(err.Error(), test.wantError) {
+				t.Fatalf("got error %v, want one containing %q", err, test.wantError)
+			}
+		})
+	}
+}
+
+func TestDecryptRejectsTamperedEntry(t *testing.T) {
+	key := &CacheKey{Key: bytes.Repeat([]byte{1}, 32)}
+	sealed, err := encryptEntry(key, "dev", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry encryptedEntry
+	if err = json.Unmarshal(sealed, &entry); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _ := base64.StdEncoding.DecodeString(entry.Ciphertext)
+	ciphertext[0] ^= 0xff
+	entry.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	tampered, _ := json.Marshal(entry)
+
+	if _, err = decryptEntry(key, "dev", tampered); err == nil {
+		t.Fatal("tampered entry was decrypted")
+	}
+}
+
+func TestEncryptedEntryIsBoundToProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		profileName string
+		wantError   bool
+	}{
+		{"same profile", "dev", false},
+		{"other profile", "prod", true},
+		{"profile name prefix", "de", true},
+	}
+
+	for _, key := range []*CacheKey{{Key: bytes.Repeat([]byte{1}, 32)}, {Passphrase: "passphrase"}} {
+		sealed, err := encryptEntry(key, "dev", []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := decryptEntry(key, test.profileName, sealed)
+				if (err != nil) != test.wantError {
+					t.Fatalf("got error %v, want error %v", err, test.wantError)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadCacheKey(t *testing.T) {
+	resetHome(t)
+	cacheConfig := &CacheConfig{KeyFile: filepath.Join(testHome, "keys", "cache.key")}
+
+	if _, err := LoadCacheKey(cacheConfig, false); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("got error %v for a missing key file without create", err)
+	}
+
+	created, err := LoadCacheKey(cacheConfig, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCacheKey(cacheConfig, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(created.Key, loaded.Key) {
+		t.Fatal("the key read back differs from the created key")
+	}
+
+	t.Setenv(CachePassphraseEnv, "passphrase")
+	if key, err := LoadCacheKey(cacheConfig, false); err != nil || key.Passphrase != "passphrase" || key.Key != nil {
+		t.Fatalf("got key %+v and error %v, want the passphrase", key, err)
+	}
+}
+
+func TestLoadCacheKeyCreatesOneKeyConcurrently(t *testing.T) {
+	resetHome(t)
+	writeTestConfig(t, "{}")
+	cacheConfig := &CacheConfig{KeyFile: filepath.Join(testHome, "cache.key")}
+
+	keys := make([]*CacheKey, 8)
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys[i], errs[i] = LoadCacheKey(cacheConfig, true)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range keys {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if !bytes.Equal(keys[i].Key, keys[0].Key) {
+			t.Fatal("concurrent callers created different keys")
+		}
+	}
+}
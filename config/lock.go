@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLockTimeout bounds how long Maroon waits for another process to release a lock
+const DefaultLockTimeout = 2 * time.Minute
+
+const lockPollInterval = 25 * time.Millisecond
+
+// FileLock is an advisory lock held on a file, shared by every Maroon process on the machine
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile takes an exclusive advisory lock on path, creating it if needed, and waits up to timeout for another
+// process holding it to release it
+func LockFile(path string, timeout time.Duration) (*FileLock, error) {
+	return lockFile(path, timeout, true)
+}
+
+// lockFile takes an exclusive or shared advisory lock on path. Any number of shared locks may be held at once, but
+// never together with an exclusive one.
+func lockFile(path string, timeout time.Duration, exclusive bool) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "Cannot create lock folder")
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot open lock file")
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file, exclusive)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "Cannot lock file")
+		} else if locked {
+			return &FileLock{file: file}, nil
+		}
+
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, errors.New(fmt.Sprintf("Timed out after %v waiting for lock '%s'", timeout, path))
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	defer l.file.Close()
+	return unlock(l.file)
+}
+
+// lockConfig locks the Maroon config for a read-modify-write
+func lockConfig() (*FileLock, error) {
+	return lockConfigFile(true)
+}
+
+// rLockConfig locks the Maroon config for reading. Readers do not block each other, only writers.
+func rLockConfig() (*FileLock, error) {
+	return lockConfigFile(false)
+}
+
+func lockConfigFile(exclusive bool) (*FileLock, error) {
+	configPath, err := GetMaroonConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get config path")
+	}
+
+	return lockFile(configPath+".lock", DefaultLockTimeout, exclusive)
+}
+
+// LockProfile locks a profile's credential cache entry so only one process refreshes its credentials at a time.
+// Processes that wait for the lock should re-read the cache once they hold it.
+func LockProfile(profileName string) (*FileLock, error) {
+	cacheDir, err := GetCredentialCacheDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get credential cache path")
+	}
+
+	return LockFile(filepath.Join(cacheDir, profileName+".lock"), DefaultLockTimeout)
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockFileTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := LockFile(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Unlock()
+
+	start := time.Now()
+	lock, err := LockFile(path, 100*time.Millisecond)
+	if err == nil {
+		lock.Unlock()
+		t.Fatal("expected the second lock to time out")
+	}
+	if !strings.Contains(err.Error(), "Timed out") {
+		t.Errorf("error = %q, want a timeout", err.Error())
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("gave up after %v, before the timeout", elapsed)
+	}
+}
+
+func TestLockFileWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	held, err := LockFile(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(100*time.Millisecond, func() { held.Unlock() })
+
+	lock, err := LockFile(path, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+}
+
+func TestLockProfileExclusion(t *testing.T) {
+	resetHome(t)
+
+	// Each LockProfile call opens the lock file again, so goroutines contend like separate processes would
+	var holders, maxHolders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			lock, err := LockProfile("dev")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer lock.Unlock()
+
+			current := atomic.AddInt32(&holders, 1)
+			for {
+				seen := atomic.LoadInt32(&maxHolders)
+				if current <= seen || atomic.CompareAndSwapInt32(&maxHolders, seen, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("%d goroutines held the profile lock at once, want 1", maxHolders)
+	}
+}
+
+func TestLockFileShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	first, err := lockFile(path, time.Second, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := lockFile(path, 100*time.Millisecond, false)
+	if err != nil {
+		t.Fatalf("shared locks should not block each other: %v", err)
+	}
+
+	if lock, err := lockFile(path, 100*time.Millisecond, true); err == nil {
+		lock.Unlock()
+		t.Fatal("exclusive lock was taken while shared locks were held")
+	}
+
+	first.Unlock()
+	second.Unlock()
+
+	exclusive, err := lockFile(path, time.Second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exclusive.Unlock()
+
+	if lock, err := lockFile(path, 100*time.Millisecond, false); err == nil {
+		lock.Unlock()
+		t.Fatal("shared lock was taken while an exclusive lock was held")
+	}
+}
+
+func TestReadMaroonConfigDoesNotBlockReaders(t *testing.T) {
+	resetHome(t)
+	writeTestConfig(t, `{"Contexts":{"default":{"endpoint":"https://maroon.example.com"}}}`)
+
+	held, err := rLockConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := readMaroonConfig()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading the config waited for another reader")
+	}
+}
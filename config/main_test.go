@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// testHome is the home folder of every test in the package. The home folder is cached on first use, so it cannot
+// change between tests and resetHome empties it instead.
+var testHome string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "maroon-config-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	testHome = dir
+	os.Setenv("HOME", dir)
+	os.Setenv("USERPROFILE", dir)
+	os.Unsetenv(CachePassphraseEnv)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// resetHome removes everything a previous test left in the home folder
+func resetHome(t *testing.T) {
+	t.Helper()
+
+	entries, err := os.ReadDir(testHome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if err = os.RemoveAll(filepath.Join(testHome, entry.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unreadableWarned.Range(func(key, value interface{}) bool {
+		unreadableWarned.Delete(key)
+		return true
+	})
+}
+
+// writeTestConfig writes config as the Maroon config
+func writeTestConfig(t *testing.T, config string) {
+	t.Helper()
+
+	configPath, err := GetMaroonConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writeFileAtomic(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCredentials(accessKeyId string) types.Credentials {
+	return types.Credentials{
+		AccessKeyId:     aws.String(accessKeyId),
+		SecretAccessKey: aws.String("secret-" + accessKeyId),
+		SessionToken:    aws.String("token-" + accessKeyId),
+		Expiration:      aws.Time(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+}
+
+func sameCredentials(a types.Credentials, b types.Credentials) bool {
+	return aws.ToString(a.AccessKeyId) == aws.ToString(b.AccessKeyId) &&
+		aws.ToString(a.SecretAccessKey) == aws.ToString(b.SecretAccessKey) &&
+		aws.ToString(a.SessionToken) == aws.ToString(b.SessionToken) &&
+		aws.ToTime(a.Expiration).Equal(aws.ToTime(b.Expiration))
+}
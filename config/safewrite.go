@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hunoz/spark/homedir"
+	"github.com/pkg/errors"
+)
+
+// Names of the files Maroon manages, used to label their backups
+const (
+	ManagedConfig         = "config"
+	ManagedAwsConfig      = "aws-config"
+	ManagedAwsCredentials = "aws-credentials"
+)
+
+// BackupCount is the number of previous versions kept for each managed file
+const BackupCount = 5
+
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// ManagedFile is a file Maroon rewrites. Every write is atomic and keeps a backup of the previous version.
+type ManagedFile struct {
+	Name     string
+	Path     string
+	LockPath string
+}
+
+// Backup is a previous version of a managed file
+type Backup struct {
+	Path    string
+	Created time.Time
+}
+
+// GetManagedFiles returns the Maroon config, the AWS config and the AWS credentials file
+func GetManagedFiles() ([]ManagedFile, error) {
+	configPath, err := GetMaroonConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get config path")
+	}
+
+	homeDir, err := homedir.Dir()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find home folder.")
+	}
+
+	maroonDir := filepath.Dir(configPath)
+	return []ManagedFile{
+		{Name: ManagedConfig, Path: configPath, LockPath: configPath + ".lock"},
+		{Name: ManagedAwsConfig, Path: filepath.Join(homeDir, ".aws", "config"), LockPath: filepath.Join(maroonDir, ManagedAwsConfig+".lock")},
+		{Name: ManagedAwsCredentials, Path: filepath.Join(homeDir, ".aws", "credentials"), LockPath: filepath.Join(maroonDir, ManagedAwsCredentials+".lock")},
+	}, nil
+}
+
+// GetManagedFile returns the managed file with the given name
+func GetManagedFile(name string) (*ManagedFile, error) {
+	files, err := GetManagedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if file.Name == name {
+			return &file, nil
+		}
+		names = append(names, file.Name)
+	}
+
+	return nil, errors.New(fmt.Sprintf("Unknown file '%s'. Must be one of %s", name, strings.Join(names, ", ")))
+}
+
+// GetBackupDir returns the folder holding backups of managed files
+func GetBackupDir() (string, error) {
+	configPath, err := GetMaroonConfigFile()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(configPath), "backups"), nil
+}
+
+// UpdateFile locks a managed file, passes its current content to update and atomically replaces it with what
+// update writes to dest
+func UpdateFile(file *ManagedFile, update func(in io.Reader, dest io.Writer) error) error {
+	lock, err := LockFile(file.LockPath, DefaultLockTimeout)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Could not lock '%s'", file.Path))
+	}
+	defer lock.Unlock()
+
+	current, err := os.ReadFile(file.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, fmt.Sprintf("Failed to read '%s'", file.Path))
+	}
+
+	var updated bytes.Buffer
+	if err = update(bytes.NewReader(current), &updated); err != nil {
+		return err
+	}
+
+	return writeManagedFile(file, updated.Bytes())
+}
+
+// writeManagedFile backs up the current content of a managed file and atomically replaces it with data. Callers must
+// hold the file's lock.
+func writeManagedFile(file *ManagedFile, data []byte) error {
+	current, err := os.ReadFile(file.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, fmt.Sprintf("Failed to read '%s'", file.Path))
+	}
+
+	if len(current) > 0 && !bytes.Equal(current, data) {
+		if err = backupFile(file, current); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(file.Path, data, 0600)
+}
+
+// writeFileAtomic writes data to a temporary file in the same folder as path, syncs it and renames it over path,
+// so a crash or full disk leaves either the previous or the new content. The mode of an existing file is kept.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Cannot create folder '%s'", dir))
+	}
+
+	if stat, err := os.Stat(path); err == nil {
+		perm = stat.Mode().Perm()
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to create temporary file for '%s'", path))
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err = tmpFile.Write(data); err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to write '%s'", path))
+	}
+
+	if err = os.Chmod(tmpFile.Name(), perm); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to set permissions of '%s'", path))
+	}
+
+	if err = os.Rename(tmpFile.Name(), path); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to replace '%s'", path))
+	}
+
+	// Sync the folder so the rename itself survives a crash. Not every platform supports this, so errors are ignored.
+	if dirFile, err := os.Open(dir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
+
+// backupFile stores content as the newest backup of a managed file and removes backups beyond BackupCount
+func backupFile(file *ManagedFile, content []byte) error {
+	backupDir, err := GetBackupDir()
+	if err != nil {
+		return err
+	}
+
+	backupPath := filepath.Join(backupDir, file.Name+"."+time.Now().UTC().Format(backupTimeFormat))
+	if err = writeFileAtomic(backupPath, content, 0600); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to back up '%s'", file.Path))
+	}
+
+	backups, err := ListBackups(file)
+	if err != nil {
+		return err
+	}
+
+	for i, backup := range backups {
+		if i < BackupCount {
+			continue
+		}
+		if err = os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "Failed to remove old backup")
+		}
+	}
+
+	return nil
+}
+
+// ListBackups returns the backups of a managed file, newest first
+func ListBackups(file *ManagedFile) ([]Backup, error) {
+	backupDir, err := GetBackupDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Failed to list backups")
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), file.Name+".")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		created, err := time.Parse(backupTimeFormat, suffix)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{Path: filepath.Join(backupDir, entry.Name()), Created: created})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+
+	return backups, nil
+}
+
+// RestoreBackup replaces a managed file with one of its backups. The current content is backed up first, so a
+// restore can itself be rolled back.
+func RestoreBackup(file *ManagedFile, backup Backup) error {
+	content, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read backup")
+	}
+
+	return UpdateFile(file, func(in io.Reader, dest io.Writer) error {
+		_, err := dest.Write(content)
+		return err
+	})
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		existing *os.FileMode
+		perm     os.FileMode
+		wantPerm os.FileMode
+	}{
+		{"new file in a new folder", nil, 0600, 0600},
+		{"replaces an existing file and keeps its mode", fileMode(0640), 0600, 0640},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprint(i), "file")
+			if test.existing != nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("old"), *test.existing); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(path, *test.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeFileAtomic(path, []byte("new"), test.perm); err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "new" {
+				t.Errorf("content = %q, want %q", content, "new")
+			}
+
+			stat, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runtime.GOOS != "windows" && stat.Mode().Perm() != test.wantPerm {
+				t.Errorf("mode = %v, want %v", stat.Mode().Perm(), test.wantPerm)
+			}
+
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					t.Errorf("temporary file '%s' was left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func fileMode(mode os.FileMode) *os.FileMode {
+	return &mode
+}
+
+func TestBackupRotation(t *testing.T) {
+	resetHome(t)
+	file := testManagedFile(t)
+
+	// The first write has nothing to back up, every later one backs up the content it replaces
+	writes := BackupCount + 3
+	for i := 0; i < writes; i++ {
+		writeTestVersion(t, file, i)
+	}
+
+	backups, err := ListBackups(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != BackupCount {
+		t.Fatalf("%d backups were kept, want %d", len(backups), BackupCount)
+	}
+
+	// Newest first, so the newest backup is the version before the current one
+	for i, backup := range backups {
+		content, err := os.ReadFile(backup.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("version %d", writes-2-i); string(content) != want {
+			t.Errorf("backup %d = %q, want %q", i, content, want)
+		}
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	resetHome(t)
+	file := testManagedFile(t)
+
+	backupDir, err := GetBackupDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(backupDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	names := []string{
+		file.Name + "." + base.Add(time.Hour).Format(backupTimeFormat),
+		file.Name + "." + base.Format(backupTimeFormat),
+		file.Name + "." + base.Add(2*time.Hour).Format(backupTimeFormat),
+		// Backups of other files and names that are not backups are ignored
+		ManagedAwsCredentials + "." + base.Add(3*time.Hour).Format(backupTimeFormat),
+		file.Name + ".not-a-time",
+	}
+	for _, name := range names {
+		if err = os.WriteFile(filepath.Join(backupDir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := ListBackups(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Time{base.Add(2 * time.Hour), base.Add(time.Hour), base}
+	if len(backups) != len(want) {
+		t.Fatalf("listed %d backups, want %d", len(backups), len(want))
+	}
+	for i, backup := range backups {
+		if !backup.Created.Equal(want[i]) {
+			t.Errorf("backup %d was created %v, want %v", i, backup.Created, want[i])
+		}
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	resetHome(t)
+	file := testManagedFile(t)
+
+	for i := 0; i < 3; i++ {
+		writeTestVersion(t, file, i)
+	}
+
+	backups, err := ListBackups(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldest := backups[len(backups)-1]
+
+	if err = RestoreBackup(file, oldest); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "version 0" {
+		t.Errorf("restored content = %q, want %q", content, "version 0")
+	}
+
+	// The content replaced by the restore is backed up, so the restore can be rolled back
+	backups, err = ListBackups(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(backups[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "version 2" {
+		t.Errorf("newest backup = %q, want %q", content, "version 2")
+	}
+}
+
+func testManagedFile(t *testing.T) *ManagedFile {
+	t.Helper()
+
+	file, err := GetManagedFile(ManagedAwsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func writeTestVersion(t *testing.T, file *ManagedFile, version int) {
+	t.Helper()
+
+	err := UpdateFile(file, func(in io.Reader, dest io.Writer) error {
+		_, err := fmt.Fprintf(dest, "version %d", version)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limits of the session duration AWS allows when assuming a role
+const (
+	MinSessionDuration = 15 * time.Minute
+	MaxSessionDuration = 12 * time.Hour
+)
+
+// DefaultSessionDuration is requested for profiles without a configured session duration
+const DefaultSessionDuration = time.Hour
+
+// DefaultRefreshBefore is how long before they expire cached credentials are refreshed, unless configured otherwise
+const DefaultRefreshBefore = 15 * time.Minute
+
+// SessionConfig holds the session duration and refresh window used for profiles that do not set their own.
+// Durations are written like 2h, 45m or a number of seconds.
+type SessionConfig struct {
+	SessionDuration string `json:"sessionDuration,omitempty"`
+	RefreshBefore   string `json:"refreshBefore,omitempty"`
+}
+
+// ParseDuration parses a duration written like 2h or 45m, or a plain number of seconds
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid duration '%s'. Use a number of seconds or a duration like 2h or 45m", value))
+	}
+
+	return duration, nil
+}
+
+// ValidateSessionDuration checks that a session duration is within the limits AWS allows and a whole number of seconds
+func ValidateSessionDuration(duration time.Duration) error {
+	if duration < MinSessionDuration || duration > MaxSessionDuration {
+		return errors.New(fmt.Sprintf("Duration '%v' is not between %v and %v (%d and %d seconds)", duration, MinSessionDuration, MaxSessionDuration, int(MinSessionDuration.Seconds()), int(MaxSessionDuration.Seconds())))
+	} else if duration%time.Second != 0 {
+		return errors.New(fmt.Sprintf("Duration '%v' must be a whole number of seconds", duration))
+	}
+
+	return nil
+}
+
+// ValidateRefreshBefore checks that a refresh window leaves some of a session of the given duration unused
+func ValidateRefreshBefore(refreshBefore time.Duration, duration time.Duration) error {
+	if refreshBefore < 0 || refreshBefore >= duration {
+		return errors.New(fmt.Sprintf("Refresh window '%v' must be at least 0 and shorter than the session duration '%v'", refreshBefore, duration))
+	}
+
+	return nil
+}
+
+// validateProfileSession checks that the session duration and refresh window of a profile are valid durations
+func validateProfileSession(profile Profile) error {
+	if profile.SessionDuration != "" {
+		duration, err := ParseDuration(profile.SessionDuration)
+		if err != nil {
+			return err
+		} else if err = ValidateSessionDuration(duration); err != nil {
+			return err
+		}
+	}
+
+	if profile.RefreshBefore != "" {
+		refreshBefore, err := ParseDuration(profile.RefreshBefore)
+		if err != nil {
+			return err
+		} else if refreshBefore < 0 {
+			return errors.New(fmt.Sprintf("Refresh window '%v' must be at least 0", refreshBefore))
+		}
+	}
+
+	return nil
+}
+
+// validateProfileRefreshBefore checks the refresh window of a profile against its session duration, or the one from
+// session when the profile does not set it
+func validateProfileRefreshBefore(profile Profile, session *SessionConfig) error {
+	if profile.RefreshBefore == "" {
+		return nil
+	}
+
+	duration := DefaultSessionDuration
+	if profile.SessionDuration != "" {
+		duration, _ = ParseDuration(profile.SessionDuration)
+	} else if session != nil && session.SessionDuration != "" {
+		if parsed, err := ParseDuration(session.SessionDuration); err == nil {
+			duration = parsed
+		}
+	}
+
+	refreshBefore, _ := ParseDuration(profile.RefreshBefore)
+	return ValidateRefreshBefore(refreshBefore, duration)
+}
+
+// SetMaxSessionDuration remembers the longest session the role of a profile allows, so later requests do not ask for
+// a longer one first. A duration of 0 forgets it.
+func SetMaxSessionDuration(profileName string, duration time.Duration) error {
+	return updateMaroonConfig(func(config *Config) error {
+		profile, ok := config.Profiles[profileName]
+		if !ok {
+			return errors.New(fmt.Sprintf("Profile '%s' does not exist", profileName))
+		}
+
+		profile.MaxSessionDuration = ""
+		if duration > 0 {
+			profile.MaxSessionDuration = duration.String()
+		}
+		config.Profiles[profileName] = profile
+		return nil
+	})
+}
+
+// ResetMaxSessionDuration forgets the session duration limit learned for a profile and reports whether it had one.
+// Profiles that are not in the Maroon config have nothing to forget.
+func ResetMaxSessionDuration(profileName string) (bool, error) {
+	reset := false
+	err := updateMaroonConfig(func(config *Config) error {
+		profile, ok := config.Profiles[profileName]
+		if !ok || profile.MaxSessionDuration == "" {
+			return nil
+		}
+
+		reset = true
+		profile.MaxSessionDuration = ""
+		config.Profiles[profileName] = profile
+		return nil
+	})
+
+	return reset, err
+}
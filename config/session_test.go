@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxSessionDuration(t *testing.T) {
+	resetHome(t)
+	writeTestConfig(t, `{"Profiles":{"prod":{"accountId":"123456789012"}}}`)
+
+	if err := SetMaxSessionDuration("prod", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := GetProfile("prod"); err != nil {
+		t.Fatal(err)
+	} else if profile.MaxSessionDuration != "2h0m0s" {
+		t.Errorf("maxSessionDuration = %q, want %q", profile.MaxSessionDuration, "2h0m0s")
+	}
+
+	reset, err := ResetMaxSessionDuration("prod")
+	if err != nil {
+		t.Fatal(err)
+	} else if !reset {
+		t.Error("ResetMaxSessionDuration() = false, want true for a learned limit")
+	}
+	if profile, err := GetProfile("prod"); err != nil {
+		t.Fatal(err)
+	} else if profile.MaxSessionDuration != "" {
+		t.Errorf("maxSessionDuration = %q after reset, want it removed", profile.MaxSessionDuration)
+	}
+
+	if reset, err = ResetMaxSessionDuration("prod"); err != nil || reset {
+		t.Errorf("ResetMaxSessionDuration() without a limit = %v, %v, want false", reset, err)
+	}
+	if reset, err = ResetMaxSessionDuration("unknown"); err != nil || reset {
+		t.Errorf("ResetMaxSessionDuration() of an unknown profile = %v, %v, want false", reset, err)
+	}
+
+	if err = SetMaxSessionDuration("prod", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = SetMaxSessionDuration("prod", 0); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := GetProfile("prod"); err != nil {
+		t.Fatal(err)
+	} else if profile.MaxSessionDuration != "" {
+		t.Errorf("maxSessionDuration = %q after setting 0, want it removed", profile.MaxSessionDuration)
+	}
+}
+
+func TestAddProfileSessionValidation(t *testing.T) {
+	tests := []struct {
+		name            string
+		sessionDuration string
+		refreshBefore   string
+		wantError       bool
+	}{
+		{"no overrides", "", "", false},
+		{"valid overrides", "4h", "30m", false},
+		{"seconds", "3600", "900", false},
+		{"invalid duration", "soon", "", true},
+		{"duration below minimum", "10m", "", true},
+		{"duration above maximum", "13h", "", true},
+		{"negative refresh window", "", "-1m", true},
+		{"refresh window as long as the session", "1h", "1h", true},
+		{"refresh window longer than the config session", "", "45m", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resetHome(t)
+			writeTestConfig(t, `{"Session":{"sessionDuration":"30m"}}`)
+
+			err := AddProfile("dev", Profile{
+				AccountId:       "123456789012",
+				RoleToAssume:    "Developer",
+				Region:          "us-east-1",
+				SessionDuration: test.sessionDuration,
+				RefreshBefore:   test.refreshBefore,
+			})
+			if (err != nil) != test.wantError {
+				t.Fatalf("AddProfile() error = %v, want error %v", err, test.wantError)
+			}
+
+			_, err = GetProfile("dev")
+			if test.wantError && err == nil {
+				t.Error("profile was added despite invalid session settings")
+			} else if !test.wantError && err != nil {
+				t.Errorf("profile was not added: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hunoz/spark/homedir"
+	"github.com/pkg/errors"
+
+	sparkConfig "github.com/hunoz/spark/config"
+)
+
+// ExpandPath replaces a leading '~' in path with the user's home folder
+func ExpandPath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to find home folder.")
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// GetCognitoConfigFromFile reads the Cognito config from a Spark config file at path
+func GetCognitoConfigFromFile(path string) (*sparkConfig.CognitoConfig, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read Spark config")
+	}
+
+	var config sparkConfig.Config
+	if err = json.Unmarshal(configBytes, &config); err != nil {
+		return nil, errors.Wrap(err, "Failed to unmarshal Spark config")
+	}
+
+	return &config.Cognito, nil
+}
@@ -0,0 +1,52 @@
+package metadata
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ECSCredentialsPath is the path the container credentials endpoint serves credentials on
+const ECSCredentialsPath = "/credentials"
+
+// ecsCredentials is the response format of the ECS container credentials endpoint
+type ecsCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+	RoleArn         string `json:",omitempty"`
+}
+
+// NewECSHandler serves credentials the way the ECS container credentials endpoint does, as read by AWS SDKs from
+// AWS_CONTAINER_CREDENTIALS_FULL_URI. Requests must carry authToken in the Authorization header, which SDKs send
+// from AWS_CONTAINER_AUTHORIZATION_TOKEN.
+func NewECSHandler(authToken string, roleArn string, credentials CredentialsFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ECSCredentialsPath {
+			writeError(w, http.StatusNotFound, "Not found")
+			return
+		} else if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		} else if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(authToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "Invalid authorization token")
+			return
+		}
+
+		current, err := credentials()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("Could not get credentials: %v", err.Error()))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ecsCredentials{
+			AccessKeyId:     *current.AccessKeyId,
+			SecretAccessKey: *current.SecretAccessKey,
+			Token:           *current.SessionToken,
+			Expiration:      current.Expiration.UTC().Format(time.RFC3339),
+			RoleArn:         roleArn,
+		})
+	})
+}
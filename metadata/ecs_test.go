@@ -0,0 +1,89 @@
+package metadata
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestECSHandler(t *testing.T) {
+	const authToken = "secret-authorization-token"
+	handler := NewECSHandler(authToken, "arn:aws:iam::123456789012:role/admin", testCredentials)
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{"valid token", http.MethodGet, ECSCredentialsPath, authToken, http.StatusOK},
+		{"missing token", http.MethodGet, ECSCredentialsPath, "", http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, ECSCredentialsPath, "wrong-authorization-token", http.StatusUnauthorized},
+		{"token prefix", http.MethodGet, ECSCredentialsPath, authToken[:10], http.StatusUnauthorized},
+		{"bearer token", http.MethodGet, ECSCredentialsPath, "Bearer " + authToken, http.StatusUnauthorized},
+		{"post", http.MethodPost, ECSCredentialsPath, authToken, http.StatusMethodNotAllowed},
+		{"other path", http.MethodGet, "/other", authToken, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestECSCredentials(t *testing.T) {
+	const authToken = "secret-authorization-token"
+	handler := NewECSHandler(authToken, "arn:aws:iam::123456789012:role/admin", testCredentials)
+
+	req := httptest.NewRequest(http.MethodGet, ECSCredentialsPath, nil)
+	req.Header.Set("Authorization", authToken)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	// The fields the AWS SDKs read from the container credentials endpoint
+	var body map[string]string
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"AccessKeyId":     "ASIAEXAMPLE",
+		"SecretAccessKey": "secret",
+		"Token":           "session-token",
+		"Expiration":      "2030-01-01T00:00:00Z",
+		"RoleArn":         "arn:aws:iam::123456789012:role/admin",
+	}
+	if len(body) != len(want) {
+		t.Errorf("response = %v, want exactly %v", body, want)
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %q, want %q", key, body[key], value)
+		}
+	}
+
+	failing := NewECSHandler(authToken, "", failingCredentials)
+	recorder = httptest.NewRecorder()
+	failing.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("status without credentials = %d, want 500", recorder.Code)
+	}
+}
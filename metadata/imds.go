@@ -0,0 +1,244 @@
+package metadata
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// MaxIMDSTokenTTL is the longest session token lifetime IMDSv2 allows
+const MaxIMDSTokenTTL = 6 * time.Hour
+
+// DefaultIMDSHopLimit is the hop limit EC2 applies to IMDSv2 responses by default, so they never leave the host
+const DefaultIMDSHopLimit = 1
+
+const (
+	imdsTokenPath     = "/latest/api/token"
+	imdsTokenHeader   = "X-aws-ec2-metadata-token"
+	imdsTTLHeader     = "X-aws-ec2-metadata-token-ttl-seconds"
+	imdsMetadataPath  = "/latest/meta-data/"
+	imdsIdentityPath  = "/latest/dynamic/instance-identity/document"
+	imdsCredentialDir = "iam/security-credentials/"
+)
+
+// imdsCredentials is the response format of the instance metadata security credentials
+type imdsCredentials struct {
+	Code            string
+	LastUpdated     string
+	Type            string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+// instanceIdentity is the subset of the instance identity document SDKs read the region from
+type instanceIdentity struct {
+	AccountId        string `json:"accountId"`
+	AvailabilityZone string `json:"availabilityZone"`
+	Region           string `json:"region"`
+}
+
+// IMDSHandler serves credentials the way the EC2 instance metadata service does with IMDSv2 required. Session tokens
+// are issued from PUT /latest/api/token and must be sent with every metadata request.
+type IMDSHandler struct {
+	RoleName    string
+	Region      string
+	AccountId   string
+	Credentials CredentialsFunc
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// NewIMDSHandler creates an IMDSv2 handler that serves a role's credentials and region
+func NewIMDSHandler(roleName string, region string, accountId string, credentials CredentialsFunc) *IMDSHandler {
+	return &IMDSHandler{
+		RoleName:    roleName,
+		Region:      region,
+		AccountId:   accountId,
+		Credentials: credentials,
+		tokens:      map[string]time.Time{},
+	}
+}
+
+func (h *IMDSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Like EC2, refuse requests that went through a proxy, so a misconfigured proxy cannot expose credentials
+	if r.Header.Get("X-Forwarded-For") != "" {
+		writeText(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	if r.URL.Path == imdsTokenPath {
+		h.issueToken(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeText(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	} else if !h.validToken(r.Header.Get(imdsTokenHeader)) {
+		writeText(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if r.URL.Path == imdsIdentityPath {
+		writeJSON(w, http.StatusOK, instanceIdentity{
+			AccountId:        h.AccountId,
+			AvailabilityZone: h.availabilityZone(),
+			Region:           h.Region,
+		})
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, imdsMetadataPath)
+	if !ok {
+		writeText(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	switch path {
+	case "":
+		writeText(w, http.StatusOK, "iam/\nplacement/")
+	case "iam/":
+		writeText(w, http.StatusOK, "security-credentials/")
+	case imdsCredentialDir:
+		writeText(w, http.StatusOK, h.RoleName)
+	case imdsCredentialDir + h.RoleName, imdsCredentialDir + h.RoleName + "/":
+		h.serveCredentials(w)
+	case "placement/":
+		writeText(w, http.StatusOK, "availability-zone\nregion")
+	case "placement/region":
+		writeText(w, http.StatusOK, h.Region)
+	case "placement/availability-zone":
+		writeText(w, http.StatusOK, h.availabilityZone())
+	default:
+		writeText(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// issueToken creates a session token that is valid for the TTL the client asks for
+func (h *IMDSHandler) issueToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeText(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	ttlSeconds, err := strconv.Atoi(r.Header.Get(imdsTTLHeader))
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if err != nil || ttl < time.Second || ttl > MaxIMDSTokenTTL {
+		writeText(w, http.StatusBadRequest, fmt.Sprintf("%s must be between 1 and %d", imdsTTLHeader, int(MaxIMDSTokenTTL.Seconds())))
+		return
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		writeText(w, http.StatusInternalServerError, "Could not create token")
+		return
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	for existing, expiry := range h.tokens {
+		if now.After(expiry) {
+			delete(h.tokens, existing)
+		}
+	}
+	h.tokens[token] = now.Add(ttl)
+	h.mu.Unlock()
+
+	w.Header().Set(imdsTTLHeader, strconv.Itoa(ttlSeconds))
+	writeText(w, http.StatusOK, token)
+}
+
+// validToken reports whether token was issued by this handler and has not expired
+func (h *IMDSHandler) validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for issued, expiry := range h.tokens {
+		if subtle.ConstantTimeCompare([]byte(issued), []byte(token)) == 1 {
+			return time.Now().Before(expiry)
+		}
+	}
+	return false
+}
+
+func (h *IMDSHandler) serveCredentials(w http.ResponseWriter) {
+	current, err := h.Credentials()
+	if err != nil {
+		writeText(w, http.StatusInternalServerError, fmt.Sprintf("Could not get credentials: %v", err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, imdsCredentials{
+		Code:            "Success",
+		LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+		Type:            "AWS-HMAC",
+		AccessKeyId:     *current.AccessKeyId,
+		SecretAccessKey: *current.SecretAccessKey,
+		Token:           *current.SessionToken,
+		Expiration:      current.Expiration.UTC().Format(time.RFC3339),
+	})
+}
+
+// availabilityZone returns a placeholder availability zone in the profile's region
+func (h *IMDSHandler) availabilityZone() string {
+	return h.Region + "a"
+}
+
+// LimitHops sets the IP TTL, or the IPv6 hop limit, of every connection accepted by listener to hops, like EC2 does
+// for IMDSv2 responses. Responses are dropped by the first router past the limit, so session tokens cannot reach
+// callers further away, i.e. containers on a bridge network when hops is 1.
+func LimitHops(listener net.Listener, hops int) net.Listener {
+	return &hopLimitListener{Listener: listener, hops: hops}
+}
+
+type hopLimitListener struct {
+	net.Listener
+	hops int
+}
+
+func (l *hopLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		// Connections whose hop limit cannot be set are dropped rather than served without it
+		if err = setHopLimit(conn, l.hops); err != nil {
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+func setHopLimit(conn net.Conn, hops int) error {
+	address, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return errors.New(fmt.Sprintf("Cannot set the hop limit of a %s connection", conn.LocalAddr().Network()))
+	}
+
+	// IPv4 clients of a listener on an IPv6 address arrive as IPv4-mapped addresses, on an IPv6 socket
+	if address.IP.To4() != nil {
+		if err := ipv4.NewConn(conn).SetTTL(hops); err == nil {
+			return nil
+		}
+	}
+	return ipv6.NewConn(conn).SetHopLimit(hops)
+}
@@ -0,0 +1,203 @@
+package metadata
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// imdsRequest sends a request with headers to handler and returns the response
+func imdsRequest(t *testing.T, handler http.Handler, method string, path string, headers map[string]string) *http.Response {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Result()
+}
+
+// imdsToken asks handler for a session token valid for ttl seconds
+func imdsToken(t *testing.T, handler http.Handler, ttl string) string {
+	t.Helper()
+
+	resp := imdsRequest(t, handler, http.MethodPut, imdsTokenPath, map[string]string{imdsTTLHeader: ttl})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("token request returned %d, want 200", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestIMDSTokenRequest(t *testing.T) {
+	handler := NewIMDSHandler("admin", "us-east-1", "123456789012", testCredentials)
+
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"valid ttl", http.MethodPut, map[string]string{imdsTTLHeader: "21600"}, http.StatusOK},
+		{"missing ttl", http.MethodPut, nil, http.StatusBadRequest},
+		{"zero ttl", http.MethodPut, map[string]string{imdsTTLHeader: "0"}, http.StatusBadRequest},
+		{"ttl above six hours", http.MethodPut, map[string]string{imdsTTLHeader: "21601"}, http.StatusBadRequest},
+		{"invalid ttl", http.MethodPut, map[string]string{imdsTTLHeader: "soon"}, http.StatusBadRequest},
+		{"get", http.MethodGet, map[string]string{imdsTTLHeader: "60"}, http.StatusMethodNotAllowed},
+		{"forwarded", http.MethodPut, map[string]string{imdsTTLHeader: "60", "X-Forwarded-For": "10.0.0.1"}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := imdsRequest(t, handler, test.method, imdsTokenPath, test.headers)
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestIMDSRequiresToken(t *testing.T) {
+	handler := NewIMDSHandler("admin", "us-east-1", "123456789012", testCredentials)
+	token := imdsToken(t, handler, "60")
+
+	expired := imdsToken(t, handler, "60")
+	handler.tokens[expired] = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"valid token", map[string]string{imdsTokenHeader: token}, http.StatusOK},
+		{"missing token", nil, http.StatusUnauthorized},
+		{"wrong token", map[string]string{imdsTokenHeader: strings.Repeat("0", len(token))}, http.StatusUnauthorized},
+		{"expired token", map[string]string{imdsTokenHeader: expired}, http.StatusUnauthorized},
+		{"forwarded", map[string]string{imdsTokenHeader: token, "X-Forwarded-For": "10.0.0.1"}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := imdsRequest(t, handler, http.MethodGet, imdsMetadataPath+imdsCredentialDir+"admin", test.headers)
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestIMDSMetadata(t *testing.T) {
+	handler := NewIMDSHandler("admin", "eu-west-1", "123456789012", testCredentials)
+	headers := map[string]string{imdsTokenHeader: imdsToken(t, handler, "60")}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{imdsMetadataPath + imdsCredentialDir, http.StatusOK, "admin"},
+		{imdsMetadataPath + "placement/region", http.StatusOK, "eu-west-1"},
+		{imdsMetadataPath + "placement/availability-zone", http.StatusOK, "eu-west-1a"},
+		{imdsMetadataPath + imdsCredentialDir + "other", http.StatusNotFound, "Not found"},
+		{"/latest/user-data", http.StatusNotFound, "Not found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			resp := imdsRequest(t, handler, http.MethodGet, test.path, headers)
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != test.wantStatus || string(body) != test.wantBody {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, test.wantStatus, test.wantBody)
+			}
+		})
+	}
+
+	resp := imdsRequest(t, handler, http.MethodGet, imdsIdentityPath, headers)
+	var identity map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+		t.Fatal(err)
+	}
+	if identity["region"] != "eu-west-1" || identity["accountId"] != "123456789012" {
+		t.Errorf("identity document = %v, want the profile's region and account", identity)
+	}
+}
+
+func TestIMDSCredentials(t *testing.T) {
+	handler := NewIMDSHandler("admin", "us-east-1", "123456789012", testCredentials)
+	headers := map[string]string{imdsTokenHeader: imdsToken(t, handler, "60")}
+
+	resp := imdsRequest(t, handler, http.MethodGet, imdsMetadataPath+imdsCredentialDir+"admin", headers)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+
+	// The fields the AWS SDKs read from EC2 instance metadata credentials
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Code":            "Success",
+		"Type":            "AWS-HMAC",
+		"AccessKeyId":     "ASIAEXAMPLE",
+		"SecretAccessKey": "secret",
+		"Token":           "session-token",
+		"Expiration":      "2030-01-01T00:00:00Z",
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %q, want %q", key, body[key], value)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, body["LastUpdated"]); err != nil {
+		t.Errorf("LastUpdated = %q, want an RFC 3339 time", body["LastUpdated"])
+	}
+
+	failing := NewIMDSHandler("admin", "us-east-1", "123456789012", failingCredentials)
+	headers = map[string]string{imdsTokenHeader: imdsToken(t, failing, "60")}
+	if resp = imdsRequest(t, failing, http.MethodGet, imdsMetadataPath+imdsCredentialDir+"admin", headers); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status without credentials = %d, want 500", resp.StatusCode)
+	}
+}
+
+func TestLimitHops(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener = LimitHops(listener, DefaultIMDSHopLimit)
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ttl, err := ipv4.NewConn(conn).TTL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl != DefaultIMDSHopLimit {
+		t.Errorf("TTL = %d, want %d", ttl, DefaultIMDSHopLimit)
+	}
+}
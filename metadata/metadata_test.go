@@ -0,0 +1,26 @@
+package metadata
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/pkg/errors"
+)
+
+var testExpiration = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testCredentials is a CredentialsFunc serving fixed credentials
+func testCredentials() (types.Credentials, error) {
+	return types.Credentials{
+		AccessKeyId:     aws.String("ASIAEXAMPLE"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("session-token"),
+		Expiration:      aws.Time(testExpiration),
+	}, nil
+}
+
+// failingCredentials is a CredentialsFunc that cannot get credentials
+func failingCredentials() (types.Credentials, error) {
+	return types.Credentials{}, errors.New("Maroon API is unavailable")
+}
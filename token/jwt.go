@@ -0,0 +1,59 @@
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ClockSkew is the allowance given for differences between the local clock and the token issuer's
+const ClockSkew = 30 * time.Second
+
+// Claims are the JWT claims Maroon inspects locally. The signature is not verified, the Maroon API does that.
+type Claims struct {
+	Subject  string   `json:"sub"`
+	Username string   `json:"cognito:username"`
+	Email    string   `json:"email"`
+	Groups   []string `json:"cognito:groups"`
+	Issuer   string   `json:"iss"`
+	IssuedAt int64    `json:"iat"`
+	Expiry   int64    `json:"exp"`
+}
+
+// ParseClaims decodes the claims of a JWT without verifying its signature
+func ParseClaims(token string) (*Claims, error) {
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not decode JWT payload")
+	}
+
+	var claims Claims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.Wrap(err, "Could not unmarshal JWT claims")
+	}
+
+	return &claims, nil
+}
+
+// ExpiresAt returns when the token expires, or the zero time if it has no expiry
+func (c *Claims) ExpiresAt() time.Time {
+	if c.Expiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.Expiry, 0)
+}
+
+// Expired reports whether the token expired before now, allowing for ClockSkew
+func (c *Claims) Expired(now time.Time) bool {
+	return c.Expiry != 0 && now.After(c.ExpiresAt().Add(ClockSkew))
+}
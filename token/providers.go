@@ -0,0 +1,162 @@
+package token
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/hunoz/maroon/config"
+	"github.com/pkg/errors"
+
+	sparkConfig "github.com/hunoz/spark/config"
+)
+
+// StaticProvider returns a token that is already known, such as one passed by flag
+type StaticProvider struct {
+	Source string
+	Value  string
+}
+
+func (p StaticProvider) Name() string {
+	return p.Source
+}
+
+func (p StaticProvider) Token() (string, error) {
+	return p.Value, nil
+}
+
+// EnvProvider reads the token from an environment variable
+type EnvProvider struct {
+	Variable string
+}
+
+func (p EnvProvider) Name() string {
+	return "environment variable " + p.Variable
+}
+
+func (p EnvProvider) Token() (string, error) {
+	return os.Getenv(p.Variable), nil
+}
+
+// FileProvider reads the token from a file. An empty Path provides no token.
+type FileProvider struct {
+	Path string
+}
+
+func (p FileProvider) Name() string {
+	if p.Path == "" {
+		return "token file"
+	}
+	return "token file " + p.Path
+}
+
+func (p FileProvider) Token() (string, error) {
+	if p.Path == "" {
+		return "", nil
+	}
+
+	path, err := config.ExpandPath(p.Path)
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "Could not read token file")
+	}
+
+	return string(contents), nil
+}
+
+// ReaderProvider reads the token from a reader such as stdin
+type ReaderProvider struct {
+	Source string
+	Reader io.Reader
+}
+
+func (p ReaderProvider) Name() string {
+	return p.Source
+}
+
+func (p ReaderProvider) Token() (string, error) {
+	contents, err := io.ReadAll(p.Reader)
+	if err != nil {
+		return "", errors.Wrap(err, "Could not read token")
+	}
+
+	token := strings.TrimSpace(string(contents))
+	if token == "" {
+		return "", errors.New("No token was provided")
+	}
+
+	return token, nil
+}
+
+// CommandProvider runs a shell command and uses its output as the token. An empty Command provides no token.
+type CommandProvider struct {
+	Command string
+}
+
+func (p CommandProvider) Name() string {
+	return "token command"
+}
+
+func (p CommandProvider) Token() (string, error) {
+	if p.Command == "" {
+		return "", nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", p.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", p.Command)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrap(err, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(output), nil
+}
+
+// SparkProvider reads the Cognito ID token stored by Spark. When ConfigPath is empty, Spark's own config is used.
+type SparkProvider struct {
+	ConfigPath string
+}
+
+func (p SparkProvider) Name() string {
+	if p.ConfigPath != "" {
+		return "Spark config " + p.ConfigPath
+	}
+	return "Spark"
+}
+
+func (p SparkProvider) Token() (string, error) {
+	configuration, err := p.CognitoConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return configuration.IdToken, nil
+}
+
+// CognitoConfig returns the Spark Cognito config the provider reads its token from
+func (p SparkProvider) CognitoConfig() (*sparkConfig.CognitoConfig, error) {
+	if p.ConfigPath != "" {
+		return config.GetCognitoConfigFromFile(p.ConfigPath)
+	}
+
+	configuration, err := sparkConfig.CognitoIsInitialized()
+	if err != nil {
+		return nil, err
+	}
+
+	return configuration, nil
+}
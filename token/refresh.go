@@ -0,0 +1,35 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// RunRefreshCommand runs a command that refreshes the token, such as 'spark auth'. The command's output is sent to
+// stderr so that it never mixes with Maroon's own output. When interactive is false the command is given no stdin,
+// so a command that would prompt fails instead of hanging.
+func RunRefreshCommand(ctx context.Context, command string, interactive bool) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	if interactive {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Token refresh command '%s' failed", command))
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package token
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoToken is returned by a Chain when none of its providers has a token
+var ErrNoToken = errors.New("Token could not be found")
+
+// Provider supplies the token used to authenticate to the Maroon API. A provider that has no token to offer
+// returns an empty string and no error so that the next provider in a Chain is tried.
+type Provider interface {
+	Name() string
+	Token() (string, error)
+}
+
+// Chain tries each provider in order and returns the first token found
+type Chain []Provider
+
+// Token returns the first token found along with the name of the provider that supplied it
+func (c Chain) Token() (string, string, error) {
+	for _, provider := range c {
+		token, err := provider.Token()
+		if err != nil {
+			return "", provider.Name(), errors.Wrap(err, fmt.Sprintf("Error getting token from %s", provider.Name()))
+		}
+
+		if token = strings.TrimSpace(token); token != "" {
+			return token, provider.Name(), nil
+		}
+	}
+
+	return "", "", ErrNoToken
+}
+
+// Names returns the names of the providers in the chain
+func (c Chain) Names() []string {
+	names := make([]string, 0, len(c))
+	for _, provider := range c {
+		names = append(names, provider.Name())
+	}
+	return names
+}